@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/michaelschlottmann/darts-web/internal/auth"
+	"github.com/michaelschlottmann/darts-web/internal/backup"
+	"github.com/michaelschlottmann/darts-web/internal/importer"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// runCommand executes a maintenance command instead of starting the server
+func runCommand(dbPath string, args []string) error {
+	switch args[0] {
+	case "recompute-ratings":
+		return recomputeRatings(dbPath)
+	case "rebuild-stats":
+		return rebuildStats(dbPath)
+	case "backup":
+		return createBackup(dbPath)
+	case "restore":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: restore <backup-file>")
+		}
+		return restoreBackup(dbPath, args[1])
+	case "import":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: import <archive.json|archive.csv>")
+		}
+		return importArchive(dbPath, args[1])
+	case "migrate":
+		return migrate(dbPath, args[1:])
+	case "account":
+		return account(dbPath, args[1:])
+	case "token":
+		if len(args) != 4 || args[1] != "add" {
+			return fmt.Errorf("usage: token add <name> <admin|scorer>")
+		}
+		return addAPIToken(dbPath, args[2], models.Role(args[3]))
+	default:
+		return fmt.Errorf("unknown command %q (available: recompute-ratings, rebuild-stats, backup, restore, import, migrate, account, token)", args[0])
+	}
+}
+
+func recomputeRatings(dbPath string) error {
+	db, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	games, err := db.RecomputeRatings()
+	if err != nil {
+		return err
+	}
+	log.Printf("Recomputed ratings from %d finished games", games)
+	return nil
+}
+
+func rebuildStats(dbPath string) error {
+	db, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	games, err := db.RebuildStatsAggregates()
+	if err != nil {
+		return err
+	}
+	log.Printf("Rebuilt statistics aggregates from %d finished games", games)
+	return nil
+}
+
+func createBackup(dbPath string) error {
+	cfg, err := loadBackupConfig(dbPath)
+	if err != nil {
+		return err
+	}
+
+	db, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	path, err := backup.NewManager(db, cfg.dir, cfg.retention).Create()
+	if err != nil {
+		return err
+	}
+	log.Printf("Created backup %s", path)
+	return nil
+}
+
+func restoreBackup(dbPath, backupPath string) error {
+	if usePostgres() {
+		return fmt.Errorf("restore only works on SQLite; restore PostgreSQL with pg_restore")
+	}
+	if err := store.RestoreBackup(backupPath, dbPath); err != nil {
+		return err
+	}
+	log.Printf("Restored %s from %s; the previous database was kept as %s.pre-restore", dbPath, backupPath, dbPath)
+	return nil
+}
+
+func importArchive(dbPath, archivePath string) error {
+	format := importer.FormatJSON
+	if strings.EqualFold(filepath.Ext(archivePath), ".csv") {
+		format = importer.FormatCSV
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive, err := importer.Read(f, format)
+	if err != nil {
+		return err
+	}
+
+	db, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := importer.Import(db, archive)
+	if err != nil {
+		return err
+	}
+	for _, e := range report.Errors {
+		log.Printf("Skipped %s", e.Error())
+	}
+	log.Printf("Imported %d games, %d games failed", report.GamesImported, report.GamesFailed)
+	return nil
+}
+
+const migrateUsage = "usage: migrate status|up|down <version>"
+
+func migrate(dbPath string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(db)
+	case "up":
+		n, err := db.MigrateUp()
+		if err != nil {
+			return err
+		}
+		log.Printf("Applied %d migrations", n)
+		return nil
+	case "down":
+		if len(args) != 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		n, err := db.MigrateDown(version)
+		if err != nil {
+			return err
+		}
+		log.Printf("Reverted %d migrations", n)
+		return nil
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+}
+
+func printMigrationStatus(db *store.SQLStore) error {
+	states, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, m := range states {
+		status, appliedAt := "pending", ""
+		if m.Applied {
+			status = "applied"
+			appliedAt = m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case m.Unknown:
+			status = "unknown (newer release)"
+		case m.Modified:
+			status = "modified"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.Version, m.Name, status, appliedAt)
+	}
+	return w.Flush()
+}
+
+const accountUsage = "usage: account add <username> <admin|scorer> | account password <username>"
+
+// account creates the first accounts, or resets a forgotten password, without
+// logging in. The password is read from ACCOUNT_PASSWORD or the first line of
+// stdin, so it does not show up in the shell history.
+func account(dbPath string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(accountUsage)
+	}
+
+	switch {
+	case args[0] == "add" && len(args) == 3:
+		role := models.Role(args[2])
+		if !role.Valid() {
+			return store.ErrInvalidRole
+		}
+		hash, err := readPasswordHash()
+		if err != nil {
+			return err
+		}
+
+		db, err := openStore(dbPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		a, err := db.CreateAccount(args[1], hash, role)
+		if err != nil {
+			return err
+		}
+		log.Printf("Created %s account %s", a.Role, a.Username)
+		return nil
+	case args[0] == "password" && len(args) == 2:
+		hash, err := readPasswordHash()
+		if err != nil {
+			return err
+		}
+
+		db, err := openStore(dbPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		a, _, err := db.GetAccountByUsername(args[1])
+		if err != nil {
+			return err
+		}
+		if a == nil {
+			return fmt.Errorf("no account %q", args[1])
+		}
+		if err := db.SetAccountPassword(a.ID, hash); err != nil {
+			return err
+		}
+		log.Printf("Set the password of %s and ended its sessions", a.Username)
+		return nil
+	default:
+		return fmt.Errorf(accountUsage)
+	}
+}
+
+// readPasswordHash hashes the password from ACCOUNT_PASSWORD or stdin
+func readPasswordHash() (string, error) {
+	password := os.Getenv("ACCOUNT_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	return auth.HashPassword(password)
+}
+
+// addAPIToken creates an API token and prints it. It is not shown again.
+func addAPIToken(dbPath, name string, role models.Role) error {
+	if !role.Valid() {
+		return store.ErrInvalidRole
+	}
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	db, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	t, err := db.CreateAPIToken(name, role, hash)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created %s token %q (id %d); send it as \"Authorization: Bearer <token>\"", t.Role, t.Name, t.ID)
+	fmt.Println(token)
+	return nil
+}
@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/michaelschlottmann/darts-web/internal/auth"
+	"github.com/michaelschlottmann/darts-web/internal/avatar"
+	"github.com/michaelschlottmann/darts-web/internal/backup"
+	"github.com/michaelschlottmann/darts-web/internal/demo"
 	"github.com/michaelschlottmann/darts-web/internal/handlers"
+	"github.com/michaelschlottmann/darts-web/internal/models"
 	"github.com/michaelschlottmann/darts-web/internal/store"
 )
 
@@ -24,64 +32,103 @@ func main() {
 		dbPath = "./darts.db"
 	}
 
-	basePath := os.Getenv("BASE_PATH") // e.g., "/darts"
-	corsOrigin := getEnv("CORS_ORIGIN", "*")
+	// Maintenance commands, e.g. "darts-server recompute-ratings"
+	if len(os.Args) > 1 {
+		if err := runCommand(dbPath, os.Args[1:]); err != nil {
+			log.Fatalf("Command failed: %v", err)
+		}
+		return
+	}
+
+	basePath := os.Getenv("BASE_PATH")     // e.g., "/darts"
+	corsOrigin := os.Getenv("CORS_ORIGIN") // e.g., "http://localhost:5173"
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	sessionTTL, err := time.ParseDuration(getEnv("SESSION_TTL", "720h"))
+	if err != nil {
+		log.Fatalf("Invalid SESSION_TTL: %v", err)
+	}
+	demoMode, _ := strconv.ParseBool(os.Getenv("DEMO_MODE"))
+
+	backupCfg, err := loadBackupConfig(dbPath)
+	if err != nil {
+		log.Fatalf("Invalid backup configuration: %v", err)
+	}
+
+	// Profile pictures go next to the database by default, i.e. onto the
+	// same volume. Demo pictures are thrown away with the demo data.
+	avatarDir := getEnv("AVATAR_DIR", filepath.Join(filepath.Dir(dbPath), "avatars"))
+	if demoMode {
+		if avatarDir, err = os.MkdirTemp("", "darts-avatars-"); err != nil {
+			log.Fatalf("Failed to create avatar directory: %v", err)
+		}
+		defer os.RemoveAll(avatarDir)
+	}
 
 	log.Printf("Starting Darts Web Server")
 	log.Printf("Port: %s", port)
-	log.Printf("Database: %s", dbPath)
+	if demoMode {
+		log.Printf("Database: in memory with demo data (DEMO_MODE), reset on every restart")
+	} else if usePostgres() {
+		log.Printf("Database: PostgreSQL (DATABASE_URL)")
+	} else {
+		log.Printf("Database: %s", dbPath)
+	}
 	log.Printf("Base Path: %s", basePath)
+	if corsOrigin != "" {
+		log.Printf("CORS Origin: %s", corsOrigin)
+	}
+	log.Printf("Backups: %s (every %s, keep %d)", backupCfg.dir, backupCfg.interval, backupCfg.retention)
+	log.Printf("Avatars: %s", avatarDir)
+	log.Printf("Sessions: %s", sessionTTL)
 
 	// Database Init
-	db, err := store.NewStore(dbPath)
+	var db store.Store
+	if demoMode {
+		db, err = openDemoStore()
+	} else {
+		db, err = openStore(dbPath)
+	}
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.Close()
 
+	if demoMode {
+		log.Printf("Demo login: %s / %s", demoUsername, demoPassword)
+	} else if accounts, err := db.ListAccounts(); err == nil && len(accounts) == 0 && adminToken == "" {
+		log.Printf("WARNING: no accounts exist, so nobody can score or manage players. Create one with: darts-server account add <username> admin")
+	}
+
+	// Scheduled backups; an interval of 0 disables them. PostgreSQL is
+	// backed up with its own tools and demo data is not worth keeping.
+	backups := backup.NewManager(db, backupCfg.dir, backupCfg.retention)
+	backupCtx, stopBackups := context.WithCancel(context.Background())
+	defer stopBackups()
+	if backupCfg.interval > 0 && !usePostgres() && !demoMode {
+		go backups.Run(backupCtx, backupCfg.interval)
+	}
+
 	// Handlers Init
 	h := handlers.NewHandler(db)
-
-	mux := http.NewServeMux()
-
-	// API Routes (ensure they work with or without prefix)
-	apiPrefix := basePath + "/api"
-	mux.HandleFunc("GET "+apiPrefix+"/users", h.ListUsers)
-	mux.HandleFunc("POST "+apiPrefix+"/users", h.CreateUser)
-	mux.HandleFunc("DELETE "+apiPrefix+"/users/{id}", h.DeleteUser)
-	mux.HandleFunc("GET "+apiPrefix+"/users/{id}/stats", h.GetUserStats)
-	mux.HandleFunc("POST "+apiPrefix+"/games", h.CreateGame)
-	mux.HandleFunc("GET "+apiPrefix+"/games/{id}/statistics", h.GetGameStatistics)
-	mux.HandleFunc("GET "+apiPrefix+"/games/{id}", h.GetGame)
-	mux.HandleFunc("POST "+apiPrefix+"/games/{id}/throw", h.HandleThrow)
-
-	// Health Check
-	mux.HandleFunc("GET "+apiPrefix+"/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok"}`))
+	avatars := avatar.NewStore(avatarDir)
+	admin := handlers.NewAdminHandler(db, backups, avatars)
+	authHandler := handlers.NewAuthHandler(db, handlers.AuthConfig{
+		AdminToken: adminToken,
+		SessionTTL: sessionTTL,
+		CookiePath: basePath + "/",
 	})
 
-	// Static Files Serving
-	// We serve files from "dist" directory which will be copied into the container
-	fs := http.FileServer(http.Dir("./dist"))
+	mux := newMux(basePath, h, handlers.NewAvatarHandler(db, avatars), admin, authHandler)
 
-	// Strip the prefix if one is set, so /darts/assets/x.js -> /assets/x.js
-	var fileHandler http.Handler
-	if basePath != "" {
-		fileHandler = http.StripPrefix(basePath, fs)
-	} else {
-		fileHandler = fs
+	// Cross-origin requests are only allowed from CORS_ORIGIN, e.g. a
+	// frontend served separately
+	var handler http.Handler = mux
+	if corsOrigin != "" {
+		handler = enableCORS(mux, corsOrigin)
 	}
 
-	// Handle root and everything else with file server (SPA support would need more logic ideally,
-	// but for now, we just serve files. Accessing /darts/ should serve index.html)
-	mux.Handle(basePath+"/", fileHandler)
-
-	// Add CORS middleware
-	handler := enableCORS(mux, corsOrigin)
-
-	// Setup server with timeouts
+	// Setup server with timeouts. The exports and the backup download lift
+	// the write timeout, as they send the whole database.
 	server := &http.Server{
 		Addr:         ":" + port,
 		Handler:      handler,
@@ -104,6 +151,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopBackups()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -115,10 +163,104 @@ func main() {
 	log.Println("Server stopped")
 }
 
+// usePostgres reports whether DATABASE_URL selects a PostgreSQL database
+// instead of the SQLite file in DB_PATH
+func usePostgres() bool {
+	return os.Getenv("DATABASE_URL") != ""
+}
+
+// openStore opens the database selected by DATABASE_URL or DB_PATH
+func openStore(dbPath string) (store.Store, error) {
+	if usePostgres() {
+		return store.NewPostgresStore(os.Getenv("DATABASE_URL"))
+	}
+	opts, err := loadSQLiteOptions()
+	if err != nil {
+		return nil, err
+	}
+	return store.NewStoreWithOptions(dbPath, opts)
+}
+
+// openDatabase opens the database like openStore, but without migrating it
+func openDatabase(dbPath string) (*store.SQLStore, error) {
+	if usePostgres() {
+		return store.OpenPostgres(os.Getenv("DATABASE_URL"))
+	}
+	opts, err := loadSQLiteOptions()
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenSQLite(dbPath, opts)
+}
+
+// loadSQLiteOptions reads SQLITE_JOURNAL_MODE, SQLITE_SYNCHRONOUS,
+// SQLITE_BUSY_TIMEOUT, SQLITE_FOREIGN_KEYS and SQLITE_READ_CONNECTIONS
+func loadSQLiteOptions() (store.SQLiteOptions, error) {
+	opts := store.DefaultSQLiteOptions()
+	opts.JournalMode = getEnv("SQLITE_JOURNAL_MODE", opts.JournalMode)
+	opts.Synchronous = getEnv("SQLITE_SYNCHRONOUS", opts.Synchronous)
+
+	var err error
+	if opts.BusyTimeout, err = time.ParseDuration(getEnv("SQLITE_BUSY_TIMEOUT", opts.BusyTimeout.String())); err != nil {
+		return opts, fmt.Errorf("SQLITE_BUSY_TIMEOUT: %w", err)
+	}
+	if opts.ForeignKeys, err = strconv.ParseBool(getEnv("SQLITE_FOREIGN_KEYS", strconv.FormatBool(opts.ForeignKeys))); err != nil {
+		return opts, fmt.Errorf("SQLITE_FOREIGN_KEYS: %w", err)
+	}
+	if opts.ReadConnections, err = strconv.Atoi(getEnv("SQLITE_READ_CONNECTIONS", strconv.Itoa(opts.ReadConnections))); err != nil {
+		return opts, fmt.Errorf("SQLITE_READ_CONNECTIONS: %w", err)
+	}
+	return opts, nil
+}
+
+// demoGames is the number of finished games DEMO_MODE starts with
+const demoGames = 40
+
+// The admin account of DEMO_MODE, logged at startup so visitors can try
+// scoring
+const (
+	demoUsername = "demo"
+	demoPassword = "darts-demo"
+)
+
+// openDemoStore returns an in-memory store filled with demo players and games
+// and the demo admin account
+func openDemoStore() (store.Store, error) {
+	db, err := store.NewMemoryStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := seedDemoStore(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// seedDemoStore adds the demo players, games and account to an empty store
+func seedDemoStore(db store.Store) error {
+	if err := demo.Seed(db, demoGames, 1); err != nil {
+		return fmt.Errorf("seeding demo data: %w", err)
+	}
+	hash, err := auth.HashPassword(demoPassword)
+	if err == nil {
+		_, err = db.CreateAccount(demoUsername, hash, models.RoleAdmin)
+	}
+	if err != nil {
+		return fmt.Errorf("creating demo account: %w", err)
+	}
+	return nil
+}
+
+// enableCORS allows cross-origin requests from origin. Browsers only send the
+// session cookie along to a specific origin, never to "*".
 func enableCORS(next http.Handler, origin string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
+		if origin != "*" {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, PATCH, DELETE")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -137,3 +279,26 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+type backupConfig struct {
+	dir       string
+	interval  time.Duration
+	retention int
+}
+
+// loadBackupConfig reads BACKUP_DIR, BACKUP_INTERVAL and BACKUP_RETENTION.
+// Backups go next to the database by default, i.e. onto the same volume.
+func loadBackupConfig(dbPath string) (backupConfig, error) {
+	cfg := backupConfig{
+		dir: getEnv("BACKUP_DIR", filepath.Join(filepath.Dir(dbPath), "backups")),
+	}
+
+	var err error
+	if cfg.interval, err = time.ParseDuration(getEnv("BACKUP_INTERVAL", "24h")); err != nil {
+		return cfg, fmt.Errorf("BACKUP_INTERVAL: %w", err)
+	}
+	if cfg.retention, err = strconv.Atoi(getEnv("BACKUP_RETENTION", "7")); err != nil {
+		return cfg, fmt.Errorf("BACKUP_RETENTION: %w", err)
+	}
+	return cfg, nil
+}
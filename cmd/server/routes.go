@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/michaelschlottmann/darts-web/internal/handlers"
+)
+
+// newMux registers the API routes under basePath+"/api" and serves the
+// frontend from ./dist for everything else
+func newMux(basePath string, h *handlers.Handler, avatars *handlers.AvatarHandler, admin *handlers.AdminHandler, a *handlers.AuthHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// API Routes (ensure they work with or without prefix)
+	apiPrefix := basePath + "/api"
+	handlers.RegisterRoutes(mux, apiPrefix, h, avatars, admin, a)
+
+	// Health Check
+	mux.HandleFunc("GET "+apiPrefix+"/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+
+	// Static Files Serving
+	// We serve files from "dist" directory which will be copied into the container
+	fs := http.FileServer(http.Dir("./dist"))
+
+	// Strip the prefix if one is set, so /darts/assets/x.js -> /assets/x.js
+	var fileHandler http.Handler
+	if basePath != "" {
+		fileHandler = http.StripPrefix(basePath, fs)
+	} else {
+		fileHandler = fs
+	}
+
+	// Handle root and everything else with file server (SPA support would need more logic ideally,
+	// but for now, we just serve files. Accessing /darts/ should serve index.html)
+	mux.Handle(basePath+"/", fileHandler)
+
+	return mux
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/avatar"
+	"github.com/michaelschlottmann/darts-web/internal/backup"
+	"github.com/michaelschlottmann/darts-web/internal/handlers"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// TestRoutes_DemoData runs requests through the full router against the
+// demo data, as a server in DEMO_MODE would serve them
+func TestRoutes_DemoData(t *testing.T) {
+	db, err := store.NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer db.Close()
+	if err := seedDemoStore(db); err != nil {
+		t.Fatalf("Failed to seed demo store: %v", err)
+	}
+
+	backups := backup.NewManager(db, t.TempDir(), 1)
+	avatars := avatar.NewStore(t.TempDir())
+	srv := httptest.NewServer(newMux("/darts", handlers.NewHandler(db), handlers.NewAvatarHandler(db, avatars),
+		handlers.NewAdminHandler(db, backups, avatars),
+		handlers.NewAuthHandler(db, handlers.AuthConfig{AdminToken: "secret", SessionTTL: time.Hour, CookiePath: "/darts/"})))
+	defer srv.Close()
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"GET", "/darts/api/health", http.StatusOK},
+		{"GET", "/darts/api/users", http.StatusOK},
+		{"GET", "/darts/api/users?archived=true", http.StatusOK},
+		{"GET", "/darts/api/users?q=al&limit=10", http.StatusOK},
+		{"GET", "/darts/api/users/1", http.StatusOK},
+		{"GET", "/darts/api/users/9999", http.StatusNotFound},
+		{"GET", "/darts/api/users/1/avatar", http.StatusNotFound},
+		{"DELETE", "/darts/api/users/1", http.StatusUnauthorized},
+		{"POST", "/darts/api/users", http.StatusUnauthorized},
+		{"POST", "/darts/api/games", http.StatusUnauthorized},
+		{"GET", "/darts/api/auth/me", http.StatusUnauthorized},
+		{"GET", "/darts/api/users/1/stats", http.StatusOK},
+		{"GET", "/darts/api/users/1/heatmap", http.StatusOK},
+		{"GET", "/darts/api/users/1/stats/timeseries?metric=average_3_dart&bucket=week", http.StatusOK},
+		{"GET", "/darts/api/users/1/vs/2", http.StatusOK},
+		{"GET", "/darts/api/users/1/rating/history", http.StatusOK},
+		{"GET", "/darts/api/users/1/achievements", http.StatusOK},
+		{"GET", "/darts/api/ratings", http.StatusOK},
+		{"GET", "/darts/api/leaderboards?metric=average_3_dart", http.StatusOK},
+		{"GET", "/darts/api/export/throws.csv", http.StatusOK},
+		{"GET", "/darts/api/export/games.json", http.StatusOK},
+		{"GET", "/darts/api/games/1", http.StatusOK},
+		{"GET", "/darts/api/games/1/statistics", http.StatusOK},
+		{"GET", "/darts/api/games/1/timeline", http.StatusOK},
+		{"GET", "/darts/api/games/1/report.pdf", http.StatusOK},
+		{"GET", "/darts/api/games/9999", http.StatusNotFound},
+		{"GET", "/darts/api/admin/backups/latest", http.StatusUnauthorized},
+		{"POST", "/darts/api/admin/users/1/purge", http.StatusUnauthorized},
+		{"POST", "/darts/api/admin/users/1/merge", http.StatusUnauthorized},
+		{"GET", "/darts/api/admin/accounts", http.StatusUnauthorized},
+		{"GET", "/darts/api/admin/tokens", http.StatusUnauthorized},
+		{"GET", "/api/users", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, resp.StatusCode)
+		}
+	}
+
+	// The demo account logs in and deletes with its cookie
+	client := &http.Client{}
+	client.Jar, _ = cookiejar.New(nil)
+	login := `{"username": "` + demoUsername + `", "password": "` + demoPassword + `"}`
+	resp, err := client.Post(srv.URL+"/darts/api/auth/login", "application/json", strings.NewReader(login))
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for the demo login, got %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest("DELETE", srv.URL+"/darts/api/users/1", nil)
+	if resp, err = client.Do(req); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict { // Playing the game in progress
+		t.Errorf("Expected 409 deleting a player of the game in progress, got %d", resp.StatusCode)
+	}
+
+	// ADMIN_TOKEN still works for scripts
+	req, _ = http.NewRequest("GET", srv.URL+"/darts/api/admin/accounts", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 with ADMIN_TOKEN, got %d", resp.StatusCode)
+	}
+
+	// The game in progress accepts the next dart
+	resp, err = client.Get(srv.URL + "/darts/api/games/" + strconv.Itoa(demoGames+1))
+	if err != nil {
+		t.Fatalf("Failed to get game: %v", err)
+	}
+	var g struct {
+		Status  string `json:"status"`
+		Players []struct {
+			UserID int `json:"user_id"`
+		} `json:"players"`
+		CurrentTurn struct {
+			PlayerIndex int `json:"player_index"`
+		} `json:"current_turn"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&g)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode game: %v", err)
+	}
+	if g.Status == "FINISHED" {
+		t.Fatalf("Expected the last demo game to be in progress")
+	}
+
+	body := `{"user_id": ` + strconv.Itoa(g.Players[g.CurrentTurn.PlayerIndex].UserID) + `, "points": 0, "multiplier": 1}`
+	resp, err = client.Post(srv.URL+"/darts/api/games/"+strconv.Itoa(demoGames+1)+"/throw", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to post throw: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for the next dart, got %d", resp.StatusCode)
+	}
+}
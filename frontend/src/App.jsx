@@ -1,16 +1,54 @@
 
-import { useState } from 'react'
+import { useState, useEffect, useRef } from 'react'
 import './App.css'
 import GameSetup from './components/GameSetup'
 import ActiveGame from './components/ActiveGame'
 import UserStats from './components/UserStats'
 import ErrorBoundary from './components/ErrorBoundary'
+import Login from './components/Login'
+import { api, onUnauthorized } from './services/api'
 
 function App() {
-  const [view, setView] = useState('home'); // home, setup, game, stats
+  const [view, setView] = useState('home'); // home, login, setup, game, stats
   const [activeGameId, setActiveGameId] = useState(null);
+  const [account, setAccount] = useState(null);
+  // The view to go back to after logging in again
+  const returnView = useRef('setup');
 
-  const startSetup = () => setView('setup');
+  useEffect(() => {
+    api.me().then(setAccount);
+    // An ended session shows the login, and a running game continues after it
+    onUnauthorized(() => {
+      setAccount(null);
+      setView(current => {
+        if (current !== 'login') returnView.current = current;
+        return 'login';
+      });
+    });
+  }, []);
+
+  // Scoring needs an account, so a new game starts with the login if needed
+  const startSetup = () => {
+    returnView.current = 'setup';
+    setView(account ? 'setup' : 'login');
+  }
+
+  // Signing in from the nav bar keeps a running game
+  const showLogin = () => {
+    returnView.current = view === 'game' ? 'game' : 'setup';
+    setView('login');
+  }
+
+  const handleLoggedIn = (me) => {
+    setAccount(me);
+    setView(returnView.current);
+  }
+
+  const handleLogout = async () => {
+    await api.logout();
+    setAccount(null);
+    setView('home');
+  }
   const showStats = () => setView('stats');
 
   const handleGameStarted = (game) => {
@@ -32,6 +70,14 @@ function App() {
             <div className="w-8 h-8 bg-darts-blue rounded-full flex items-center justify-center text-white font-bold">D</div>
             <span className="font-bold text-xl tracking-tight text-slate-800">Darts Web</span>
           </div>
+          {account ? (
+            <div className="flex items-center gap-3 text-sm text-slate-600">
+              <span>{account.name} ({account.role})</span>
+              <button onClick={handleLogout} className="font-semibold text-darts-blue hover:underline">Sign out</button>
+            </div>
+          ) : (
+            <button onClick={showLogin} className="text-sm font-semibold text-darts-blue hover:underline">Sign in</button>
+          )}
         </nav>
 
         {/* Main Content */}
@@ -56,6 +102,10 @@ function App() {
           </div>
         )}
 
+        {view === 'login' && (
+          <Login onLoggedIn={handleLoggedIn} onCancel={handleExit} />
+        )}
+
         {view === 'setup' && (
           <GameSetup onGameStarted={handleGameStarted} />
         )}
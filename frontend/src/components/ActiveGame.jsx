@@ -189,7 +189,15 @@ export default function ActiveGame({ gameId, onExit }) {
         )}
 
         {/* Back Button */}
-        <div className="text-center">
+        <div className="text-center space-x-4">
+          <a
+            href={api.getGameReportUrl(game.id)}
+            target="_blank"
+            rel="noreferrer"
+            className="inline-block px-8 py-4 bg-white text-darts-blue text-lg font-bold rounded-lg border-2 border-darts-blue hover:bg-slate-50 transition shadow-lg"
+          >
+            Match Report (PDF)
+          </a>
           <button
             onClick={onExit}
             className="px-8 py-4 bg-darts-blue text-white text-lg font-bold rounded-lg hover:bg-blue-700 transition shadow-lg"
@@ -244,6 +252,7 @@ export default function ActiveGame({ gameId, onExit }) {
         <div className="grid grid-cols-2 gap-2 sm:gap-4 mb-4 sm:mb-8 landscape:md:grid-cols-1 landscape:md:mb-0 landscape:md:gap-3 w-full">
           {game.players.map((p, idx) => {
             const isCurrent = idx === game.current_turn.player_index;
+            const live = game.live_stats?.find(l => l.user_id === p.user_id);
             return (
               <div key={p.user_id} className={`relative p-3 sm:p-6 rounded-2xl border-2 transition-all duration-300 landscape:md:p-4 landscape:md:h-[120px] ${isCurrent ? 'bg-darts-blue text-white border-darts-blue shadow-lg sm:scale-105 landscape:md:scale-100 z-10' : 'bg-white text-slate-800 border-slate-100'}`}>
                 <div className="flex justify-between items-center mb-1 sm:mb-2">
@@ -267,6 +276,14 @@ export default function ActiveGame({ gameId, onExit }) {
                 <div className="text-4xl sm:text-6xl font-black mb-1 sm:mb-2 text-center">
                   {p.current_points}
                 </div>
+                {live && (
+                  <div className="flex justify-between text-xs opacity-80">
+                    <span>Avg {live.average_3_dart.toFixed(1)}</span>
+                    <span>Darts {live.leg_darts}</span>
+                    <span>Last {live.last_visit_score}</span>
+                    <span>CO {live.checkout_hits}/{live.checkout_attempts}</span>
+                  </div>
+                )}
               </div>
             )
           })}
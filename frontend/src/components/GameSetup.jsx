@@ -89,7 +89,7 @@ export default function GameSetup({ onGameStarted }) {
       const game = await api.createGame(settings.points, settings.sets, selectedUsers, settings.doubleOut);
       onGameStarted(game);
     } catch (e) {
-      alert('Failed to start game');
+      alert(e.message || 'Failed to start game');
     } finally {
       setLoading(false);
     }
@@ -0,0 +1,65 @@
+import { useState } from 'react';
+import { api } from '../services/api';
+
+// Login asks for an account before scoring, which needs the scorer role
+export default function Login({ onLoggedIn, onCancel }) {
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setLoading(true);
+    setError(null);
+    try {
+      await api.login(username, password);
+      onLoggedIn(await api.me());
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="max-w-sm mx-auto mt-20 p-6 bg-white rounded-xl shadow-lg border border-slate-200">
+      <h2 className="text-2xl font-bold text-slate-800 mb-6">Sign in</h2>
+      <form onSubmit={handleSubmit} className="flex flex-col gap-4">
+        <input
+          type="text"
+          autoComplete="username"
+          value={username}
+          onChange={(e) => setUsername(e.target.value)}
+          placeholder="Username"
+          className="px-4 py-2 border border-slate-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-darts-blue"
+        />
+        <input
+          type="password"
+          autoComplete="current-password"
+          value={password}
+          onChange={(e) => setPassword(e.target.value)}
+          placeholder="Password"
+          className="px-4 py-2 border border-slate-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-darts-blue"
+        />
+        {error && <p className="text-sm text-red-600">{error}</p>}
+        <div className="flex gap-2">
+          <button
+            type="button"
+            onClick={onCancel}
+            className="flex-1 py-2 rounded-lg font-semibold border text-slate-600 border-slate-300"
+          >
+            Cancel
+          </button>
+          <button
+            type="submit"
+            disabled={loading || !username || !password}
+            className="flex-1 py-2 rounded-lg font-semibold bg-darts-blue text-white disabled:opacity-50"
+          >
+            {loading ? 'Signing in...' : 'Sign in'}
+          </button>
+        </div>
+      </form>
+    </div>
+  );
+}
@@ -113,6 +113,30 @@ export default function UserStats({ onBack }) {
                     <div className="text-sm text-slate-500 mb-1">Total Throws</div>
                     <div className="text-3xl font-black text-slate-800">{stats.total_throws}</div>
                   </div>
+                  <div className="p-4 bg-slate-50 rounded-xl border border-slate-100">
+                    <div className="text-sm text-slate-500 mb-1">First 9 Average</div>
+                    <div className="text-3xl font-black text-darts-blue">{parseFloat(stats.first_9_average).toFixed(2)}</div>
+                  </div>
+                  <div className="p-4 bg-slate-50 rounded-xl border border-slate-100">
+                    <div className="text-sm text-slate-500 mb-1">Checkout %</div>
+                    <div className="text-3xl font-black text-slate-800">{parseFloat(stats.checkout_percentage).toFixed(1)}</div>
+                  </div>
+                  <div className="p-4 bg-slate-50 rounded-xl border border-slate-100">
+                    <div className="text-sm text-slate-500 mb-1">Highest Checkout</div>
+                    <div className="text-3xl font-black text-slate-800">{stats.highest_checkout}</div>
+                  </div>
+                  <div className="p-4 bg-slate-50 rounded-xl border border-slate-100">
+                    <div className="text-sm text-slate-500 mb-1">Best Leg (Darts)</div>
+                    <div className="text-3xl font-black text-slate-800">{stats.best_leg_darts || '-'}</div>
+                  </div>
+                  <div className="p-4 bg-slate-50 rounded-xl border border-slate-100">
+                    <div className="text-sm text-slate-500 mb-1">100+ / 140+ / 180</div>
+                    <div className="text-3xl font-black text-slate-800">{stats.tons_100_plus} / {stats.tons_140_plus} / {stats.maximums_180}</div>
+                  </div>
+                  <div className="p-4 bg-slate-50 rounded-xl border border-slate-100">
+                    <div className="text-sm text-slate-500 mb-1">Busts per Leg</div>
+                    <div className="text-3xl font-black text-slate-800">{parseFloat(stats.busts_per_leg).toFixed(2)}</div>
+                  </div>
                 </div>
               </div>
             ) : (
@@ -2,14 +2,57 @@
 // In development, Vite proxy or explicit VITE_API_URL can override this
 const API_URL = import.meta.env.VITE_API_URL || '/darts/api';
 
+let unauthorizedHandler = null;
+
+// onUnauthorized registers what to do when a session has ended, e.g. show the
+// login again
+export const onUnauthorized = (handler) => {
+  unauthorizedHandler = handler;
+};
+
+// request sends the session cookie along, also when VITE_API_URL points to
+// another origin allowed by the server's CORS_ORIGIN
+const request = async (path, options = {}) => {
+  const res = await fetch(`${API_URL}${path}`, { credentials: 'include', ...options });
+  if (res.status === 401 && !path.startsWith('/auth/') && unauthorizedHandler) {
+    unauthorizedHandler();
+  }
+  return res;
+};
+
 export const api = {
+  // Logs in with a session cookie, which fetch sends along on later requests
+  login: async (username, password) => {
+    const res = await request(`/auth/login`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ username, password }),
+    });
+    const data = await res.json();
+    if (!res.ok) {
+      throw new Error(data.error || 'Failed to log in');
+    }
+    return data;
+  },
+
+  logout: async () => {
+    await request(`/auth/logout`, { method: 'POST' });
+  },
+
+  // Returns the logged in account's name and role, or null
+  me: async () => {
+    const res = await request(`/auth/me`);
+    if (!res.ok) return null;
+    return res.json();
+  },
+
   getUsers: async () => {
-    const res = await fetch(`${API_URL}/users`);
+    const res = await request(`/users`);
     return res.json();
   },
 
   createUser: async (name) => {
-    const res = await fetch(`${API_URL}/users`, {
+    const res = await request(`/users`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ name }),
@@ -22,7 +65,7 @@ export const api = {
   },
 
   deleteUser: async (userId) => {
-    const res = await fetch(`${API_URL}/users/${userId}`, {
+    const res = await request(`/users/${userId}`, {
       method: 'DELETE',
     });
     if (!res.ok) {
@@ -32,7 +75,7 @@ export const api = {
   },
 
   createGame: async (totalPoints, bestOf, playerIds, doubleOut = false) => {
-    const res = await fetch(`${API_URL}/games`, {
+    const res = await request(`/games`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
@@ -42,17 +85,21 @@ export const api = {
         player_ids: playerIds
       }),
     });
-    return res.json();
+    const data = await res.json();
+    if (!res.ok) {
+      throw new Error(data.error || 'Failed to start game');
+    }
+    return data;
   },
 
   getGame: async (id) => {
-    const res = await fetch(`${API_URL}/games/${id}`);
+    const res = await request(`/games/${id}`);
     if (!res.ok) throw new Error('Game not found');
     return res.json();
   },
 
   sendThrow: async (gameId, userId, points, multiplier) => {
-    const res = await fetch(`${API_URL}/games/${gameId}/throw`, {
+    const res = await request(`/games/${gameId}/throw`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
@@ -62,6 +109,7 @@ export const api = {
       }),
     });
     if (!res.ok) {
+      if (res.status === 401) throw new Error('Please sign in to score');
       const err = await res.text();
       throw new Error(err);
     }
@@ -69,14 +117,75 @@ export const api = {
   },
 
   getUserStats: async (userId) => {
-    const res = await fetch(`${API_URL}/users/${userId}/stats`);
+    const res = await request(`/users/${userId}/stats`);
     if (!res.ok) throw new Error('Failed to load stats');
     return res.json();
   },
 
+  getUserHeatmap: async (userId, filters = {}) => {
+    const params = new URLSearchParams(filters);
+    const res = await request(`/users/${userId}/heatmap?${params}`);
+    if (!res.ok) throw new Error('Failed to load heatmap');
+    return res.json();
+  },
+
+  getUserTimeSeries: async (userId, metric = 'average_3_dart', bucket = 'week') => {
+    const params = new URLSearchParams({ metric, bucket });
+    const res = await request(`/users/${userId}/stats/timeseries?${params}`);
+    if (!res.ok) throw new Error('Failed to load statistics over time');
+    return res.json();
+  },
+
+  getHeadToHead: async (userId, otherId) => {
+    const res = await request(`/users/${userId}/vs/${otherId}`);
+    if (!res.ok) throw new Error('Failed to load head-to-head record');
+    return res.json();
+  },
+
+  getRatings: async () => {
+    const res = await request(`/ratings`);
+    if (!res.ok) throw new Error('Failed to load ratings');
+    return res.json();
+  },
+
+  getRatingHistory: async (userId) => {
+    const res = await request(`/users/${userId}/rating/history`);
+    if (!res.ok) throw new Error('Failed to load rating history');
+    return res.json();
+  },
+
+  getLeaderboard: async (metric = 'average_3_dart', period = 'all', minGames = 5) => {
+    const params = new URLSearchParams({ metric, period, min_games: minGames });
+    const res = await request(`/leaderboards?${params}`);
+    if (!res.ok) throw new Error('Failed to load leaderboard');
+    return res.json();
+  },
+
+  getAchievements: async (userId) => {
+    const res = await request(`/users/${userId}/achievements`);
+    if (!res.ok) throw new Error('Failed to load achievements');
+    return res.json();
+  },
+
   getGameStatistics: async (gameId) => {
-    const res = await fetch(`${API_URL}/games/${gameId}/statistics`);
+    const res = await request(`/games/${gameId}/statistics`);
     if (!res.ok) throw new Error('Failed to load game statistics');
     return res.json();
+  },
+
+  getGameTimeline: async (gameId) => {
+    const res = await request(`/games/${gameId}/timeline`);
+    if (!res.ok) throw new Error('Failed to load game timeline');
+    return res.json();
+  },
+
+  getGameReportUrl: (gameId) => `${API_URL}/games/${gameId}/report.pdf`,
+
+  getExportUrl: (file, filters = {}) => {
+    const params = new URLSearchParams(
+      Object.entries(filters).filter(([, value]) => value !== undefined && value !== '')
+    );
+    const query = params.toString();
+    return `${API_URL}/export/${file}${query ? `?${query}` : ''}`;
   }
 };
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("Expected the password to match its hash")
+	}
+	if CheckPassword(hash, "wrong horse") {
+		t.Error("Expected a wrong password not to match")
+	}
+	if _, err := HashPassword("short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("Expected ErrPasswordTooShort, got %v", err)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	other, _, err := NewToken()
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	if token == other {
+		t.Error("Expected different tokens")
+	}
+	if hash != HashToken(token) || hash == token {
+		t.Errorf("Expected the hash of the token, got %s", hash)
+	}
+}
@@ -0,0 +1,98 @@
+// Package avatar stores the profile pictures of players as files, next to
+// the database on the same volume.
+package avatar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaxSize is the largest accepted picture in bytes
+const MaxSize = 1 << 20
+
+var (
+	ErrTooLarge        = errors.New("avatar is too large")
+	ErrUnsupportedType = errors.New("avatar must be a PNG, JPEG or WebP image")
+)
+
+// extensions maps the accepted content types, as sniffed from the data, to
+// the file extension they are stored with
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+// Store keeps the pictures in a directory. Files are named after the player
+// and the upload time, so a new picture does not overwrite the one in use
+// until the player points to it. Clients always load GET /users/{id}/avatar,
+// which is served with no-cache and revalidated when the picture changes.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes a picture for a player and returns its file name. The type is
+// detected from the content; the client's content type is not trusted.
+func (s *Store) Save(userID int, r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxSize {
+		return "", ErrTooLarge
+	}
+	ext, ok := extensions[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrUnsupportedType
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", err
+	}
+
+	// Written under a temporary name, so a half-written picture is never
+	// served
+	name := fmt.Sprintf("%d-%d%s", userID, time.Now().UnixNano(), ext)
+	path := filepath.Join(s.dir, name)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return name, nil
+}
+
+// Open returns the picture with the given file name
+func (s *Store) Open(name string) (*os.File, error) {
+	return os.Open(s.path(name))
+}
+
+// Remove deletes a picture. An empty name or a missing file is not an error.
+func (s *Store) Remove(name string) error {
+	if name == "" {
+		return nil
+	}
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path keeps names from the database inside the directory
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, filepath.Base(name))
+}
@@ -0,0 +1,64 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file for content type detection
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestStore_SaveOpenRemove(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	name, err := s.Save(7, bytes.NewReader(pngHeader))
+	if err != nil {
+		t.Fatalf("Failed to save avatar: %v", err)
+	}
+	if !strings.HasPrefix(name, "7-") || !strings.HasSuffix(name, ".png") {
+		t.Errorf("Expected a PNG file named after user 7, got %s", name)
+	}
+
+	f, err := s.Open("../" + name)
+	if err != nil {
+		t.Fatalf("Failed to open avatar: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if !bytes.Equal(data, pngHeader) {
+		t.Errorf("Expected the uploaded data back, got %q", data)
+	}
+
+	if err := s.Remove(name); err != nil {
+		t.Fatalf("Failed to remove avatar: %v", err)
+	}
+	if err := s.Remove(name); err != nil {
+		t.Errorf("Expected removing a missing avatar to succeed, got %v", err)
+	}
+	if err := s.Remove(""); err != nil {
+		t.Errorf("Expected removing no avatar to succeed, got %v", err)
+	}
+}
+
+func TestStore_SaveRejects(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	if _, err := s.Save(1, strings.NewReader("<svg></svg>")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType, got %v", err)
+	}
+	large := append(bytes.Clone(pngHeader), make([]byte, MaxSize)...)
+	if _, err := s.Save(1, bytes.NewReader(large)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected no files after rejected uploads, got %d", len(entries))
+	}
+}
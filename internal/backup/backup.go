@@ -0,0 +1,159 @@
+// Package backup creates scheduled database snapshots and keeps a limited
+// number of them.
+package backup
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+var ErrNoBackup = errors.New("no backup available")
+
+// Backup files are named by their UTC creation time, so sorting by name
+// sorts by age
+const (
+	filePrefix = "darts-"
+	fileSuffix = ".db"
+	timeLayout = "20060102-150405.000"
+)
+
+// Manager writes backups of a store into a directory and removes the oldest
+// ones beyond the retention count
+type Manager struct {
+	store     store.Store
+	dir       string
+	retention int // Backups to keep, 0 keeps all
+	mu        sync.Mutex
+}
+
+func NewManager(s store.Store, dir string, retention int) *Manager {
+	return &Manager{store: s, dir: dir, retention: retention}
+}
+
+// Create writes a new backup and prunes old ones. It returns the path of
+// the new backup.
+func (m *Manager) Create() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return "", err
+	}
+
+	// Written under a temporary name, so an interrupted backup is never
+	// picked up as the latest one
+	path := filepath.Join(m.dir, filePrefix+time.Now().UTC().Format(timeLayout)+fileSuffix)
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	if err := m.store.Backup(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	if err := m.prune(); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// List returns the paths of all backups, oldest first
+func (m *Manager) List() ([]string, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			paths = append(paths, filepath.Join(m.dir, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Latest returns the path of the newest backup
+func (m *Manager) Latest() (string, error) {
+	paths, err := m.List()
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", ErrNoBackup
+	}
+	return paths[len(paths)-1], nil
+}
+
+func (m *Manager) prune() error {
+	if m.retention <= 0 {
+		return nil
+	}
+	paths, err := m.List()
+	if err != nil {
+		return err
+	}
+	for len(paths) > m.retention {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// Run creates a backup every interval until the context is cancelled. The
+// first one is due an interval after the newest backup, or right away, so a
+// server restarted more often than the interval still takes backups.
+// Failures are logged and retried at the next interval.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	timer := time.NewTimer(m.untilDue(interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(interval)
+			path, err := m.Create()
+			if err != nil {
+				log.Printf("Scheduled backup failed: %v", err)
+				continue
+			}
+			log.Printf("Created backup %s", path)
+		}
+	}
+}
+
+// untilDue returns how long until the next backup is due, an interval after
+// the newest one. It is 0 or less without a backup or when one is overdue.
+func (m *Manager) untilDue(interval time.Duration) time.Duration {
+	path, err := m.Latest()
+	if err != nil {
+		return 0
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), filePrefix), fileSuffix)
+	created, err := time.Parse(timeLayout, name)
+	if err != nil {
+		return 0
+	}
+	return time.Until(created.Add(interval))
+}
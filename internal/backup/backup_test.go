@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+func TestManager_CreateAndPrune(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := store.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	m := NewManager(s, dir, 2)
+	if _, err := m.Latest(); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("Expected ErrNoBackup without backups, got %v", err)
+	}
+
+	var created []string
+	for i := 0; i < 3; i++ {
+		path, err := m.Create()
+		if err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+		if err := store.VerifyBackup(path); err != nil {
+			t.Errorf("Expected valid backup, got %v", err)
+		}
+		created = append(created, path)
+	}
+
+	paths, err := m.List()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("Expected 2 backups to be kept, got %v", paths)
+	}
+	if paths[0] != created[1] || paths[1] != created[2] {
+		t.Errorf("Expected the newest backups %v, got %v", created[1:], paths)
+	}
+
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatalf("Failed to get latest backup: %v", err)
+	}
+	if latest != created[2] {
+		t.Errorf("Expected latest %s, got %s", created[2], latest)
+	}
+
+	// No temporary files are left behind
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(tmp) != 0 {
+		t.Errorf("Expected no temporary files, got %v", tmp)
+	}
+}
+
+func TestManager_RunBacksUpOnStart(t *testing.T) {
+	s, err := store.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	m := NewManager(s, t.TempDir(), 0)
+
+	// Without a backup the first one is taken right away, not a day later
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, 24*time.Hour)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := m.Latest(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a backup when Run starts")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// After a restart the next one is due a day after the newest
+	if due := m.untilDue(24 * time.Hour); due < 23*time.Hour {
+		t.Errorf("Expected the next backup in about a day, got %v", due)
+	}
+	if due := m.untilDue(0); due > 0 {
+		t.Errorf("Expected an overdue backup, got %v", due)
+	}
+}
@@ -0,0 +1,221 @@
+// Package demo fills an empty store with made-up players and games, so the
+// app can be tried out without recording anything first
+package demo
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/game"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// Players are the demo players with their accuracy, the chance to hit the
+// treble, double or bull they aim at
+var Players = []struct {
+	Name  string
+	Skill float64
+}{
+	{"Alice", 0.45},
+	{"Bob", 0.30},
+	{"Charlie", 0.38},
+	{"Dana", 0.50},
+	{"Eve", 0.25},
+	{"Frank", 0.35},
+}
+
+// maxDarts stops a simulation that never finishes
+const maxDarts = 5000
+
+// board lists the segments clockwise, for darts that drift to a neighbour
+var board = []int{20, 1, 18, 4, 13, 6, 10, 15, 2, 17, 3, 19, 7, 16, 8, 11, 14, 9, 12, 5}
+
+// Seed creates the demo players, plays the given number of finished games
+// between them, one per day up to today, and leaves one game in progress.
+// The same seed always produces the same games.
+func Seed(s store.Store, games int, seed uint64) error {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	for _, p := range Players {
+		if _, err := s.CreateUser(p.Name); err != nil {
+			return err
+		}
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := 0; i < games; i++ {
+		playedAt := today.AddDate(0, 0, i-games).Add(18*time.Hour + time.Duration(rng.IntN(240))*time.Minute)
+		ig, err := simulate(rng, fmt.Sprintf("demo-%d", i+1), playedAt)
+		if err != nil {
+			return err
+		}
+		if _, err := s.ImportGame(ig); err != nil {
+			return err
+		}
+	}
+	if _, err := s.RecomputeRatings(); err != nil {
+		return err
+	}
+
+	return startGame(s, rng)
+}
+
+// simulate plays a complete game between two or three random players
+func simulate(rng *rand.Rand, ref string, playedAt time.Time) (*store.ImportGame, error) {
+	players := rng.Perm(len(Players))[:2+rng.IntN(2)]
+	ig := &store.ImportGame{
+		Ref:         ref,
+		PlayedAt:    playedAt,
+		TotalPoints: 501,
+		BestOf:      []int{1, 3, 3, 5}[rng.IntN(4)],
+		DoubleOut:   true,
+	}
+	if rng.IntN(4) == 0 {
+		ig.TotalPoints = 301
+	}
+
+	g := newGame(ig.TotalPoints, ig.BestOf, players)
+	for _, p := range players {
+		ig.Players = append(ig.Players, Players[p].Name)
+	}
+
+	engine := game.NewEngine()
+	thrownAt := playedAt
+	for dart := 1; g.Status != models.GameStatusFinished; dart++ {
+		if dart > maxDarts {
+			return nil, fmt.Errorf("demo game %s did not finish", ref)
+		}
+		current := g.Players[g.CurrentTurn.PlayerIndex]
+		points, multiplier := throwDart(rng, Players[current.UserID].Skill, current.CurrentPoints)
+		if _, err := engine.ProcessThrow(g, current.UserID, points, multiplier); err != nil {
+			return nil, err
+		}
+		thrownAt = thrownAt.Add(time.Duration(8+rng.IntN(10)) * time.Second)
+		ig.Throws = append(ig.Throws, store.ImportThrow{
+			Row:        dart,
+			Player:     Players[current.UserID].Name,
+			Points:     points,
+			Multiplier: multiplier,
+			ThrownAt:   thrownAt,
+		})
+	}
+	return ig, nil
+}
+
+// startGame creates an unfinished game between the first two players, a few
+// visits in, for the live scoreboard
+func startGame(s store.Store, rng *rand.Rand) error {
+	users, err := s.ListUsers()
+	if err != nil {
+		return err
+	}
+	skills := make(map[int]float64)
+	var playerIDs []int
+	for _, p := range Players[:2] {
+		for _, u := range users {
+			if u.Name == p.Name {
+				skills[u.ID] = p.Skill
+				playerIDs = append(playerIDs, u.ID)
+			}
+		}
+	}
+
+	g, err := s.CreateGame(501, 3, true, playerIDs)
+	if err != nil {
+		return err
+	}
+
+	engine := game.NewEngine()
+	for dart := 0; dart < 4*len(playerIDs)*3 && g.Status != models.GameStatusFinished; dart++ {
+		current := g.Players[g.CurrentTurn.PlayerIndex]
+		points, multiplier := throwDart(rng, skills[current.UserID], current.CurrentPoints)
+		t, err := engine.ProcessThrow(g, current.UserID, points, multiplier)
+		if err != nil {
+			return err
+		}
+		if _, err := s.RecordThrow(t, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newGame returns the state of a new game for the simulation, using the
+// indexes into Players as user IDs
+func newGame(totalPoints, bestOf int, players []int) *models.Game {
+	g := &models.Game{
+		Status:      models.GameStatusPending,
+		Settings:    models.GameSettings{TotalPoints: totalPoints, BestOfSets: bestOf, DoubleOut: true},
+		CurrentTurn: &models.TurnStatus{},
+	}
+	for i, p := range players {
+		g.Players = append(g.Players, models.GamePlayer{UserID: p, Order: i, CurrentPoints: totalPoints})
+	}
+	return g
+}
+
+// throwDart picks a target for the remaining score and returns where the
+// dart landed
+func throwDart(rng *rand.Rand, skill float64, remaining int) (int, int) {
+	points, multiplier := target(remaining)
+
+	switch {
+	case points == 25:
+		if rng.Float64() < skill {
+			return 25, multiplier
+		}
+		if rng.Float64() < 0.5 {
+			return 25, 1
+		}
+		return neighbour(rng, 20), 1
+	case multiplier > 1:
+		if rng.Float64() < skill {
+			return points, multiplier
+		}
+		if rng.Float64() < 0.7 {
+			return points, 1
+		}
+		if multiplier == 2 && rng.Float64() < 0.5 {
+			return 0, 1 // Outside the double
+		}
+		return neighbour(rng, points), 1
+	default:
+		if rng.Float64() < 0.6+skill/2 {
+			return points, 1
+		}
+		return neighbour(rng, points), 1
+	}
+}
+
+// target returns the segment a player aims at with the remaining score:
+// the finishing double, a single leaving a double, or the treble 20
+func target(remaining int) (int, int) {
+	switch {
+	case remaining == 50:
+		return 25, 2
+	case remaining <= 40 && remaining%2 == 0:
+		return remaining / 2, 2
+	case remaining <= 40:
+		return 1, 1
+	case remaining <= 60:
+		return remaining - 40, 1
+	default:
+		return 20, 3
+	}
+}
+
+// neighbour returns one of the two segments next to the given one
+func neighbour(rng *rand.Rand, segment int) int {
+	for i, s := range board {
+		if s == segment {
+			step := 1
+			if rng.IntN(2) == 0 {
+				step = len(board) - 1
+			}
+			return board[(i+step)%len(board)]
+		}
+	}
+	return board[rng.IntN(len(board))]
+}
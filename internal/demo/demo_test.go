@@ -0,0 +1,81 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+func seededStore(t *testing.T, games int) *store.SQLStore {
+	t.Helper()
+	s, err := store.NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if err := Seed(s, games, 1); err != nil {
+		t.Fatalf("Failed to seed demo data: %v", err)
+	}
+	return s
+}
+
+func TestSeed(t *testing.T) {
+	s := seededStore(t, 10)
+
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != len(Players) {
+		t.Fatalf("Expected %d users, got %d", len(Players), len(users))
+	}
+
+	finished := 0
+	for _, u := range users {
+		stats, err := s.GetUserStats(u.ID)
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		finished += stats.Wins
+	}
+	if finished != 10 {
+		t.Errorf("Expected 10 finished games, got %d", finished)
+	}
+
+	ratings, err := s.GetRatingLeaderboard()
+	if err != nil {
+		t.Fatalf("Failed to get ratings: %v", err)
+	}
+	if len(ratings) == 0 {
+		t.Error("Expected rated players")
+	}
+
+	live, err := s.GetGame(11)
+	if err != nil {
+		t.Fatalf("Failed to get game: %v", err)
+	}
+	if live == nil || live.Status == models.GameStatusFinished || live.CurrentTurn.VisitNumber == 0 {
+		t.Errorf("Expected the last game to be in progress, got %+v", live)
+	}
+}
+
+func TestSeed_Reproducible(t *testing.T) {
+	a := seededStore(t, 5)
+	b := seededStore(t, 5)
+
+	for id := 1; id <= len(Players); id++ {
+		statsA, err := a.GetUserStats(id)
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		statsB, err := b.GetUserStats(id)
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if *statsA != *statsB {
+			t.Errorf("User %d: expected equal stats, got %+v and %+v", id, statsA, statsB)
+		}
+	}
+}
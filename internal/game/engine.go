@@ -40,7 +40,7 @@ func (e *Engine) ProcessThrow(game *models.Game, userID int, points int, multipl
 	// Store the score at the start of this throw for bust handling
 	scoreBeforeThrow := currentPlayer.CurrentPoints
 
-	// Create throw object
+	// Create throw object, positioned within the set/leg/visit structure
 	throw := &models.Throw{
 		GameID:     game.ID,
 		UserID:     userID,
@@ -48,6 +48,10 @@ func (e *Engine) ProcessThrow(game *models.Game, userID int, points int, multipl
 		Multiplier: multiplier,
 		Valid:      true,
 		ScoreAfter: scoreBeforeThrow,
+		SetNo:      currentSetNumber(game),
+		LegNo:      1,
+		VisitNo:    game.CurrentTurn.VisitNumber + 1,
+		DartNo:     game.CurrentTurn.ThrowNumber + 1,
 	}
 
 	// Update turn stats
@@ -150,12 +154,26 @@ func (e *Engine) handleWinSet(game *models.Game, player *models.GamePlayer) {
 		}
 		// In C++, nextPlayer() is called after a set win unless match done.
 		e.nextPlayer(game)
+		// A new set starts a new leg, so visit numbering starts over
+		game.CurrentTurn.VisitNumber = 0
 	}
 }
 
+// currentSetNumber returns the 1-based number of the set being played.
+// Every finished set was won by exactly one player, so it is one more than
+// the total number of sets won so far.
+func currentSetNumber(game *models.Game) int {
+	setNo := 1
+	for _, p := range game.Players {
+		setNo += p.SetsWon
+	}
+	return setNo
+}
+
 func (e *Engine) nextPlayer(game *models.Game) {
 	game.CurrentTurn.ThrowNumber = 0
 	game.CurrentTurn.CurrentTurnPoints = 0
+	game.CurrentTurn.VisitNumber++
 
 	game.CurrentTurn.PlayerIndex++
 	if game.CurrentTurn.PlayerIndex >= len(game.Players) {
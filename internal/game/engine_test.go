@@ -541,3 +541,48 @@ func TestProcessThrow_DoubleOut_BustAfterValidThrows(t *testing.T) {
 		t.Errorf("Expected turn points to be reset to 0, got %d", game.CurrentTurn.CurrentTurnPoints)
 	}
 }
+
+func TestProcessThrow_Positions(t *testing.T) {
+	engine := NewEngine()
+
+	game := &models.Game{
+		ID:     1,
+		Status: models.GameStatusActive,
+		Settings: models.GameSettings{
+			TotalPoints: 501,
+			BestOfSets:  3,
+		},
+		Players: []models.GamePlayer{
+			{UserID: 1, Order: 0, CurrentPoints: 501, SetsWon: 0},
+			{UserID: 2, Order: 1, CurrentPoints: 40, SetsWon: 0},
+		},
+		CurrentTurn: &models.TurnStatus{
+			PlayerIndex:       0,
+			ThrowNumber:       0,
+			CurrentTurnPoints: 0,
+		},
+	}
+
+	type position struct{ set, leg, visit, dart int }
+	expected := []struct {
+		userID, points, multiplier int
+		want                       position
+	}{
+		{1, 20, 1, position{1, 1, 1, 1}},
+		{1, 20, 1, position{1, 1, 1, 2}},
+		{1, 20, 1, position{1, 1, 1, 3}},
+		{2, 20, 2, position{1, 1, 2, 1}}, // Checkout wins set 1
+		{1, 20, 1, position{2, 1, 1, 1}}, // Set 2 restarts visit numbering
+	}
+
+	for i, e := range expected {
+		throw, err := engine.ProcessThrow(game, e.userID, e.points, e.multiplier)
+		if err != nil {
+			t.Fatalf("throw %d: ProcessThrow() error = %v", i, err)
+		}
+		got := position{throw.SetNo, throw.LegNo, throw.VisitNo, throw.DartNo}
+		if got != e.want {
+			t.Errorf("throw %d: expected position %+v, got %+v", i, e.want, got)
+		}
+	}
+}
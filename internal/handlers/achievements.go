@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) GetAchievements(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	achievements, err := h.store.GetAchievements(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get achievements")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, achievements)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/michaelschlottmann/darts-web/internal/avatar"
+	"github.com/michaelschlottmann/darts-web/internal/backup"
+	"github.com/michaelschlottmann/darts-web/internal/importer"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// maxImportSize limits the size of an uploaded archive
+const maxImportSize = 32 << 20
+
+// AdminHandler serves the maintenance endpoints. The routes require the
+// admin role (see AuthHandler).
+type AdminHandler struct {
+	store   store.Store
+	backups *backup.Manager
+	avatars *avatar.Store
+}
+
+func NewAdminHandler(s store.Store, backups *backup.Manager, avatars *avatar.Store) *AdminHandler {
+	return &AdminHandler{store: s, backups: backups, avatars: avatars}
+}
+
+func (a *AdminHandler) DownloadLatestBackup(w http.ResponseWriter, r *http.Request) {
+	path, err := a.backups.Latest()
+	if errors.Is(err, backup.ErrNoBackup) {
+		writeError(w, http.StatusNotFound, "No backup available")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to find latest backup: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to find backup")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open backup %s: %v", path, err)
+		writeError(w, http.StatusInternalServerError, "Failed to open backup")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to open backup")
+		return
+	}
+
+	// A large backup over a slow link takes longer than the write timeout
+	clearWriteDeadline(w)
+
+	name := filepath.Base(path)
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// Import imports an archive of games sent as the request body. The format is
+// CSV for a text/csv content type or format=csv, JSON otherwise. Games with
+// errors are listed in the report; the others are imported.
+func (a *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	format := importer.FormatJSON
+	if r.URL.Query().Get("format") == "csv" || strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		format = importer.FormatCSV
+	}
+
+	archive, err := importer.Read(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid archive: %v", err))
+		return
+	}
+
+	report, err := importer.Import(a.store, archive)
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to import archive")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// PurgeUser removes a player's personal data and avatar for good, either by
+// renaming them or by deleting all their games. The request must repeat the
+// player's current name as confirmation.
+func (a *AdminHandler) PurgeUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Mode    store.PurgeMode `json:"mode"`
+		Confirm string          `json:"confirm"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := a.store.GetUser(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if req.Confirm != user.Name {
+		writeError(w, http.StatusBadRequest, "Confirm must be the user's name")
+		return
+	}
+
+	games, err := a.store.PurgeUser(id, req.Mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidPurgeMode):
+			writeError(w, http.StatusBadRequest, "Mode must be anonymise or delete")
+		case errors.Is(err, store.ErrUserInActiveGame):
+			writeError(w, http.StatusConflict, "User is playing an unfinished game")
+		case errors.Is(err, sql.ErrNoRows):
+			writeError(w, http.StatusNotFound, "User not found")
+		default:
+			log.Printf("Failed to purge user %d: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "Failed to purge user")
+		}
+		return
+	}
+
+	if err := a.avatars.Remove(user.Avatar); err != nil {
+		log.Printf("Failed to remove avatar %s: %v", user.Avatar, err)
+	}
+
+	log.Printf("Purged user %d (%s), %d games deleted", id, req.Mode, games)
+	writeJSON(w, http.StatusOK, map[string]int{"games_deleted": games})
+}
+
+// MergeUsers merges the player given as "from" into the player in the path
+// and archives them. With dry_run the report lists what would change.
+func (a *AdminHandler) MergeUsers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		From              int  `json:"from"`
+		DryRun            bool `json:"dry_run"`
+		DeleteSharedGames bool `json:"delete_shared_games"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, err := a.store.MergeUsers(id, req.From, store.MergeOptions{
+		DryRun:            req.DryRun,
+		DeleteSharedGames: req.DeleteSharedGames,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMergeSameUser):
+			writeError(w, http.StatusBadRequest, "Cannot merge a user into themselves")
+		case errors.Is(err, store.ErrUserInActiveGame):
+			writeError(w, http.StatusConflict, "User is playing an unfinished game")
+		case errors.Is(err, sql.ErrNoRows):
+			writeError(w, http.StatusNotFound, "User not found")
+		default:
+			log.Printf("Failed to merge user %d into %d: %v", req.From, id, err)
+			writeError(w, http.StatusInternalServerError, "Failed to merge users")
+		}
+		return
+	}
+
+	if !req.DryRun {
+		log.Printf("Merged user %d into %d, %d games moved", req.From, id, report.Games)
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/auth"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// sessionCookie holds the session token of the web UI
+const sessionCookie = "darts_session"
+
+// AuthConfig configures the authentication of AuthHandler
+type AuthConfig struct {
+	// AdminToken is accepted as bearer token with the admin role, for
+	// scripts written before accounts existed. Empty disables it.
+	AdminToken string
+	// SessionTTL is how long a login lasts
+	SessionTTL time.Duration
+	// CookiePath limits the session cookie to the app, e.g. "/darts/"
+	CookiePath string
+}
+
+// AuthHandler logs accounts in and out, manages accounts and API tokens,
+// and guards the endpoints that change data. Requests authenticate with a
+// bearer token in the Authorization header or with the session cookie.
+type AuthHandler struct {
+	store store.Store
+	cfg   AuthConfig
+}
+
+func NewAuthHandler(s store.Store, cfg AuthConfig) *AuthHandler {
+	return &AuthHandler{store: s, cfg: cfg}
+}
+
+// caller is who sent a request
+type caller struct {
+	Name string      `json:"name"`
+	Role models.Role `json:"role"`
+}
+
+// authenticate returns the caller of a request, or nil without valid
+// credentials. A bearer token takes precedence over the session cookie.
+func (a *AuthHandler) authenticate(r *http.Request) (*caller, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return nil, nil
+		}
+		if a.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.AdminToken)) == 1 {
+			return &caller{Name: "ADMIN_TOKEN", Role: models.RoleAdmin}, nil
+		}
+		t, err := a.store.UseAPIToken(auth.HashToken(token))
+		if err != nil || t == nil {
+			return nil, err
+		}
+		return &caller{Name: "token " + t.Name, Role: t.Role}, nil
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	account, err := a.store.GetSession(auth.HashToken(cookie.Value))
+	if err != nil || account == nil {
+		return nil, err
+	}
+	return &caller{Name: account.Username, Role: account.Role}, nil
+}
+
+// Require rejects requests without credentials with 401, and those of
+// callers whose role does not allow the endpoint with 403
+func (a *AuthHandler) Require(role models.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := a.authenticate(r)
+		if err != nil {
+			log.Printf("Failed to authenticate request: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to authenticate")
+			return
+		}
+		if c == nil {
+			writeError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !c.Role.Allows(role) {
+			writeError(w, http.StatusForbidden, "This requires the "+string(role)+" role")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireScorer allows scorers and admins
+func (a *AuthHandler) RequireScorer(next http.HandlerFunc) http.HandlerFunc {
+	return a.Require(models.RoleScorer, next)
+}
+
+// RequireAdmin allows admins only
+func (a *AuthHandler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return a.Require(models.RoleAdmin, next)
+}
+
+// Login checks a username and password and starts a session in a cookie
+func (a *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	account, hash, err := a.store.GetAccountByUsername(req.Username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if account == nil {
+		auth.CheckNoPassword(req.Password)
+	}
+	if account == nil || !auth.CheckPassword(hash, req.Password) {
+		log.Printf("Failed login for %q", req.Username)
+		writeError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	token, tokenHash, err := auth.NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	expires := time.Now().Add(a.cfg.SessionTTL)
+	if err := a.store.CreateSession(tokenHash, account.ID, expires); err != nil {
+		log.Printf("Failed to create session: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	a.setSessionCookie(w, r, token, expires)
+	writeJSON(w, http.StatusOK, account)
+}
+
+// Logout ends the session of the cookie
+func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := a.store.DeleteSession(auth.HashToken(cookie.Value)); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+	a.setSessionCookie(w, r, "", time.Unix(0, 0))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me returns the name and role of the caller, so the web UI knows whether it
+// is logged in
+func (a *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	c, err := a.authenticate(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to authenticate")
+		return
+	}
+	if c == nil {
+		writeError(w, http.StatusUnauthorized, "Not logged in")
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// setSessionCookie sets the session cookie, or removes it with a past
+// expiry. SameSite keeps other sites from sending it along.
+func (a *AuthHandler) setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     a.cfg.CookiePath,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (a *AuthHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := a.store.ListAccounts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list accounts")
+		return
+	}
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+func (a *AuthHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string      `json:"username"`
+		Password string      `json:"password"`
+		Role     models.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !validName(strings.TrimSpace(req.Username)) {
+		writeError(w, http.StatusBadRequest, "Username must be between 1 and 100 characters")
+		return
+	}
+	if !req.Role.Valid() {
+		writeError(w, http.StatusBadRequest, "Role must be admin or scorer")
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordTooShort) {
+			writeError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	account, err := a.store.CreateAccount(req.Username, hash, req.Role)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateAccount) {
+			writeError(w, http.StatusConflict, "Account already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+	writeJSON(w, http.StatusCreated, account)
+}
+
+// SetAccountPassword replaces the password of an account, which logs it out
+func (a *AuthHandler) SetAccountPassword(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordTooShort) {
+			writeError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+
+	if err := a.store.SetAccountPassword(id, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "Account not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	if err := a.store.DeleteAccount(id); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeError(w, http.StatusNotFound, "Account not found")
+		case errors.Is(err, store.ErrLastAdmin):
+			writeError(w, http.StatusConflict, "Cannot delete the last admin account")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to delete account")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AuthHandler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := a.store.ListAPITokens()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list tokens")
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// CreateAPIToken creates a token for a kiosk or integration. The response is
+// the only place the token is shown.
+func (a *AuthHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string      `json:"name"`
+		Role models.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !validName(strings.TrimSpace(req.Name)) {
+		writeError(w, http.StatusBadRequest, "Name must be between 1 and 100 characters")
+		return
+	}
+	if !req.Role.Valid() {
+		writeError(w, http.StatusBadRequest, "Role must be admin or scorer")
+		return
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	t, err := a.store.CreateAPIToken(req.Name, req.Role, hash)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, struct {
+		*models.APIToken
+		Token string `json:"token"`
+	}{t, token})
+}
+
+func (a *AuthHandler) DeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	if err := a.store.DeleteAPIToken(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "Token not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to delete token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// createTestAccount adds an account with the password "<username>-password"
+func createTestAccount(t *testing.T, mux *http.ServeMux, username string, role models.Role) models.Account {
+	t.Helper()
+	var a models.Account
+	body := map[string]string{"username": username, "password": username + "-password", "role": string(role)}
+	if code := do(t, mux, "POST", "/api/admin/accounts", body, &a); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating account %s, got %d", username, code)
+	}
+	return a
+}
+
+// login returns the session cookie of an account
+func login(t *testing.T, mux *http.ServeMux, username, password string) *http.Cookie {
+	t.Helper()
+	rec := serve(t, mux, "POST", "/api/auth/login", map[string]string{"username": username, "password": password}, anonymous)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 logging in as %s, got %d", username, rec.Code)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookie {
+			if !c.HttpOnly {
+				t.Error("Expected an HttpOnly session cookie")
+			}
+			return c
+		}
+	}
+	t.Fatalf("Expected a session cookie logging in as %s", username)
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	mux := newTestServer(t)
+	createTestAccount(t, mux, "admin", models.RoleAdmin)
+	createTestAccount(t, mux, "scorer", models.RoleScorer)
+
+	for _, creds := range []map[string]string{
+		{"username": "admin", "password": "wrong-password"},
+		{"username": "nobody", "password": "admin-password"},
+	} {
+		if code := do(t, mux, "POST", "/api/auth/login", creds, nil, anonymous); code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %v, got %d", creds, code)
+		}
+	}
+
+	cookie := login(t, mux, "Scorer", "scorer-password")
+	var me caller
+	if code := do(t, mux, "GET", "/api/auth/me", nil, &me, withCookie(cookie)); code != http.StatusOK {
+		t.Fatalf("Expected 200 for me, got %d", code)
+	}
+	if me.Name != "scorer" || me.Role != models.RoleScorer {
+		t.Errorf("Expected the scorer, got %+v", me)
+	}
+
+	if code := do(t, mux, "POST", "/api/auth/logout", nil, nil, withCookie(cookie)); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 logging out, got %d", code)
+	}
+	if code := do(t, mux, "GET", "/api/auth/me", nil, nil, withCookie(cookie)); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 after logging out, got %d", code)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	mux := newTestServer(t)
+	createTestAccount(t, mux, "admin", models.RoleAdmin)
+	createTestAccount(t, mux, "scorer", models.RoleScorer)
+	scorer := withCookie(login(t, mux, "scorer", "scorer-password"))
+	admin := withCookie(login(t, mux, "admin", "admin-password"))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cred   credential
+		want   int
+	}{
+		{"anonymous create", "POST", "/api/users", anonymous, http.StatusUnauthorized},
+		{"unknown token", "POST", "/api/users", withBearer("guess"), http.StatusUnauthorized},
+		{"scorer create", "POST", "/api/users", scorer, http.StatusCreated},
+		{"scorer delete", "DELETE", "/api/users/1", scorer, http.StatusForbidden},
+		{"scorer purge", "POST", "/api/admin/users/1/purge", scorer, http.StatusForbidden},
+		{"scorer accounts", "GET", "/api/admin/accounts", scorer, http.StatusForbidden},
+		{"anonymous read", "GET", "/api/users/1", anonymous, http.StatusOK},
+		{"admin delete", "DELETE", "/api/users/1", admin, http.StatusNoContent},
+		{"admin token", "GET", "/api/admin/accounts", withBearer(testAdminToken), http.StatusOK},
+	}
+	for _, tt := range tests {
+		var body interface{}
+		if tt.method == "POST" {
+			body = map[string]string{"name": "Alice"}
+		}
+		if code := do(t, mux, tt.method, tt.path, body, nil, tt.cred); code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+}
+
+func TestAPITokenAuth(t *testing.T) {
+	mux := newTestServer(t)
+
+	var created struct {
+		ID    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	if code := do(t, mux, "POST", "/api/admin/tokens", map[string]string{"name": "Kiosk", "role": "scorer"}, &created); code != http.StatusCreated || created.Token == "" {
+		t.Fatalf("Expected 201 with the token, got %d", code)
+	}
+	if code := do(t, mux, "POST", "/api/admin/tokens", map[string]string{"name": "Kiosk", "role": "owner"}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown role, got %d", code)
+	}
+	kiosk := withBearer(created.Token)
+
+	if code := do(t, mux, "POST", "/api/users", map[string]string{"name": "Alice"}, nil, kiosk); code != http.StatusCreated {
+		t.Errorf("Expected 201 scoring with the token, got %d", code)
+	}
+	if code := do(t, mux, "DELETE", "/api/users/1", nil, nil, kiosk); code != http.StatusForbidden {
+		t.Errorf("Expected 403 deleting with a scorer token, got %d", code)
+	}
+
+	if code := do(t, mux, "DELETE", "/api/admin/tokens/"+strconv.Itoa(created.ID), nil, nil); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 revoking the token, got %d", code)
+	}
+	if code := do(t, mux, "POST", "/api/users", map[string]string{"name": "Bob"}, nil, kiosk); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a revoked token, got %d", code)
+	}
+}
+
+func TestManageAccounts(t *testing.T) {
+	mux := newTestServer(t)
+	admin := createTestAccount(t, mux, "admin", models.RoleAdmin)
+
+	tests := []struct {
+		body map[string]string
+		want int
+	}{
+		{map[string]string{"username": "kiosk", "password": "long enough", "role": "scorer"}, http.StatusCreated},
+		{map[string]string{"username": "KIOSK", "password": "long enough", "role": "scorer"}, http.StatusConflict},
+		{map[string]string{"username": "other", "password": "short", "role": "scorer"}, http.StatusBadRequest},
+		{map[string]string{"username": "other", "password": "long enough", "role": "owner"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := do(t, mux, "POST", "/api/admin/accounts", tt.body, nil); code != tt.want {
+			t.Errorf("%v: expected %d, got %d", tt.body, tt.want, code)
+		}
+	}
+	login(t, mux, "kiosk", "long enough")
+
+	var accounts []models.Account
+	if code := do(t, mux, "GET", "/api/admin/accounts", nil, &accounts); code != http.StatusOK || len(accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %d: %+v", code, accounts)
+	}
+	if code := do(t, mux, "DELETE", "/api/admin/accounts/"+strconv.Itoa(admin.ID), nil, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting the last admin, got %d", code)
+	}
+}
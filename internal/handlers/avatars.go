@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/michaelschlottmann/darts-web/internal/avatar"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// maxAvatarFormSize allows for the multipart headers around the picture
+const maxAvatarFormSize = avatar.MaxSize + 64<<10
+
+// AvatarHandler serves the profile pictures of players
+type AvatarHandler struct {
+	store   store.Store
+	avatars *avatar.Store
+}
+
+func NewAvatarHandler(s store.Store, avatars *avatar.Store) *AvatarHandler {
+	return &AvatarHandler{store: s, avatars: avatars}
+}
+
+// UploadAvatar replaces a player's picture with the "avatar" file of a
+// multipart form and returns the updated player
+func (a *AvatarHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarFormSize)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Avatar must be at most 1 MB")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Missing avatar file")
+		return
+	}
+	defer file.Close()
+
+	user, err := a.store.GetUser(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	name, err := a.avatars.Save(id, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, avatar.ErrTooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, "Avatar must be at most 1 MB")
+		case errors.Is(err, avatar.ErrUnsupportedType):
+			writeError(w, http.StatusUnsupportedMediaType, "Avatar must be a PNG, JPEG or WebP image")
+		default:
+			log.Printf("Failed to save avatar of user %d: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "Failed to save avatar")
+		}
+		return
+	}
+
+	previous, err := a.store.SetUserAvatar(id, name)
+	if err != nil {
+		a.removeAvatar(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to save avatar")
+		return
+	}
+	a.removeAvatar(previous)
+
+	user.Avatar = name
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (a *AvatarHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := a.store.GetUser(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+	if user == nil || user.Avatar == "" {
+		writeError(w, http.StatusNotFound, "No avatar")
+		return
+	}
+
+	f, err := a.avatars.Open(user.Avatar)
+	if err != nil {
+		log.Printf("Failed to open avatar %s: %v", user.Avatar, err)
+		writeError(w, http.StatusNotFound, "No avatar")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to open avatar")
+		return
+	}
+
+	// The URL stays the same when the picture changes
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, user.Avatar, info.ModTime(), f)
+}
+
+func (a *AvatarHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	previous, err := a.store.SetUserAvatar(id, "")
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to delete avatar")
+		return
+	}
+	a.removeAvatar(previous)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeAvatar deletes a picture that is no longer referenced. A leftover
+// file is only logged.
+func (a *AvatarHandler) removeAvatar(name string) {
+	if err := a.avatars.Remove(name); err != nil {
+		log.Printf("Failed to remove avatar %s: %v", name, err)
+	}
+}
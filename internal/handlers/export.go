@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// throwsCSVHeader is the column schema of the throws export. Columns are
+// only ever appended, so existing spreadsheets keep working.
+var throwsCSVHeader = []string{
+	"game_id", "set_no", "leg_no", "visit_no", "dart_no", "user_id", "user_name",
+	"segment", "multiplier", "score", "score_before", "score_after", "bust", "created_at",
+}
+
+// exportFlushRows is the number of rows after which the export is flushed to
+// the client
+const exportFlushRows = 500
+
+// parseExportFilter reads the user_id, game_id, from and to query parameters
+func parseExportFilter(r *http.Request) (store.ThrowFilter, error) {
+	filter, err := parseThrowFilter(r)
+	if err != nil {
+		return filter, err
+	}
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		filter.UserID, err = strconv.Atoi(userIDStr)
+		if err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// clearWriteDeadline lifts the server's WriteTimeout for a response that can
+// take longer, like an export of the whole database. Cut off by the timeout,
+// the client would get a truncated file that looks complete.
+func clearWriteDeadline(w http.ResponseWriter) {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Failed to clear write deadline: %v", err)
+	}
+}
+
+func (h *Handler) ExportThrowsCSV(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseExportFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid filter: use user_id, game_id and from/to as YYYY-MM-DD or RFC 3339")
+		return
+	}
+	clearWriteDeadline(w)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="throws.csv"`)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(throwsCSVHeader); err != nil {
+		return
+	}
+
+	rowCount := 0
+	err = h.store.ExportThrows(filter, func(t *store.ExportedThrow) error {
+		record := []string{
+			strconv.Itoa(t.GameID),
+			strconv.Itoa(t.SetNo),
+			strconv.Itoa(t.LegNo),
+			strconv.Itoa(t.VisitNo),
+			strconv.Itoa(t.DartNo),
+			strconv.Itoa(t.UserID),
+			t.UserName,
+			strconv.Itoa(t.Segment),
+			strconv.Itoa(t.Multiplier),
+			strconv.Itoa(t.Score),
+			strconv.Itoa(t.ScoreBefore),
+			strconv.Itoa(t.ScoreAfter),
+			strconv.FormatBool(t.Bust),
+			t.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		rowCount++
+		if rowCount%exportFlushRows == 0 {
+			cw.Flush()
+			flush(w)
+			return cw.Error()
+		}
+		return nil
+	})
+	cw.Flush()
+	// The status has already been sent, so failures can only be logged
+	if err != nil {
+		log.Printf("Failed to export throws: %v", err)
+	}
+}
+
+func (h *Handler) ExportGamesJSON(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseExportFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid filter: use user_id, game_id and from/to as YYYY-MM-DD or RFC 3339")
+		return
+	}
+	clearWriteDeadline(w)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="games.json"`)
+
+	// Written as a JSON array one game at a time
+	if _, err := w.Write([]byte("[")); err != nil {
+		return
+	}
+	count := 0
+	err = h.store.ExportGames(filter, func(g *store.ExportedGame) error {
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if _, err := w.Write([]byte(",\n")); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushRows == 0 {
+			flush(w)
+		}
+		return nil
+	})
+	w.Write([]byte("]\n"))
+	// The status has already been sent, so failures can only be logged
+	if err != nil {
+		log.Printf("Failed to export games: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+func TestExport_OutlastsWriteTimeout(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+	var g models.Game
+	body := map[string]interface{}{"total_points": 301, "best_of": 1, "player_ids": []int{alice.ID}}
+	if code := do(t, mux, "POST", "/api/games", body, &g); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	throw := map[string]int{"user_id": alice.ID, "points": 20, "multiplier": 3}
+	if code := do(t, mux, "POST", "/api/games/"+strconv.Itoa(g.ID)+"/throw", throw, nil); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+
+	// The export only starts after the server's write timeout has passed,
+	// like a large export does over a slow link
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		mux.ServeHTTP(w, r)
+	}))
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	for path, want := range map[string]string{
+		"/api/export/throws.csv": "\n1,1,1,1,1,",
+		"/api/export/games.json": "}]\n",
+	} {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Errorf("%s: request failed: %v", path, err)
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || !strings.Contains(string(body), want) {
+			t.Errorf("%s: expected the complete export, got %q, %v", path, body, err)
+		}
+	}
+}
@@ -7,21 +7,23 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"sync"
 
 	"github.com/michaelschlottmann/darts-web/internal/game"
+	"github.com/michaelschlottmann/darts-web/internal/models"
 	"github.com/michaelschlottmann/darts-web/internal/store"
 )
 
 type Handler struct {
-	store      *store.Store
+	store      store.Store
 	engine     *game.Engine
 	gameLocks  map[int]*sync.Mutex
 	locksMutex sync.Mutex
 }
 
-func NewHandler(s *store.Store) *Handler {
+func NewHandler(s store.Store) *Handler {
 	return &Handler{
 		store:     s,
 		engine:    game.NewEngine(),
@@ -58,12 +60,57 @@ func writeError(w http.ResponseWriter, status int, message string) {
 }
 
 // User Handlers
+
+// Page sizes of the player search
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// ListUsers returns the active players, or the archived ones with
+// archived=true. With q, limit or offset it returns a page of the players
+// whose name starts with q and sets X-Total-Count to the number of matches.
 func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.store.ListUsers()
+	query := r.URL.Query()
+	archived, _ := strconv.ParseBool(query.Get("archived"))
+
+	if !query.Has("q") && !query.Has("limit") && !query.Has("offset") {
+		list := h.store.ListUsers
+		if archived {
+			list = h.store.ListArchivedUsers
+		}
+		users, err := list()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list users")
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+		return
+	}
+
+	filter := store.UserFilter{Query: query.Get("q"), Archived: archived, Limit: defaultUserPageSize}
+	var err error
+	if query.Has("limit") {
+		filter.Limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || filter.Limit < 1 || filter.Limit > maxUserPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxUserPageSize))
+			return
+		}
+	}
+	if query.Has("offset") {
+		filter.Offset, err = strconv.Atoi(query.Get("offset"))
+		if err != nil || filter.Offset < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+	}
+
+	users, total, err := h.store.SearchUsers(filter)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to list users")
 		return
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	writeJSON(w, http.StatusOK, users)
 }
 
@@ -77,7 +124,8 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate input
-	if req.Name == "" || len(req.Name) > 100 {
+	req.Name = store.NormaliseName(req.Name)
+	if !validName(req.Name) {
 		writeError(w, http.StatusBadRequest, "Name must be between 1 and 100 characters")
 		return
 	}
@@ -94,6 +142,102 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, user)
 }
 
+// validName reports whether a normalised player name has an acceptable
+// length
+func validName(name string) bool {
+	return name != "" && len(name) <= 100
+}
+
+// walkOnColour is a colour in the #rrggbb notation
+var walkOnColour = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.store.GetUser(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// UpdateUser changes the profile of a player. Fields missing from the body
+// are left unchanged; an empty nickname or colour clears it.
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Name         *string                 `json:"name"`
+		Nickname     *string                 `json:"nickname"`
+		WalkOnColour *string                 `json:"walk_on_colour"`
+		Preferences  *models.GamePreferences `json:"preferences"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if req.Name != nil {
+		*req.Name = store.NormaliseName(*req.Name)
+	}
+	if req.Name != nil && !validName(*req.Name) {
+		writeError(w, http.StatusBadRequest, "Name must be between 1 and 100 characters")
+		return
+	}
+	if req.Nickname != nil && len(*req.Nickname) > 50 {
+		writeError(w, http.StatusBadRequest, "Nickname must be at most 50 characters")
+		return
+	}
+	if req.WalkOnColour != nil && *req.WalkOnColour != "" && !walkOnColour.MatchString(*req.WalkOnColour) {
+		writeError(w, http.StatusBadRequest, "Walk-on colour must be a #rrggbb colour")
+		return
+	}
+	if p := req.Preferences; p != nil {
+		if p.TotalPoints != 0 && p.TotalPoints != 301 && p.TotalPoints != 501 {
+			writeError(w, http.StatusBadRequest, "Total points must be 301 or 501")
+			return
+		}
+		if p.BestOf != 0 && p.BestOf != 1 && p.BestOf != 3 && p.BestOf != 5 {
+			writeError(w, http.StatusBadRequest, "Best of must be 1, 3, or 5")
+			return
+		}
+	}
+
+	user, err := h.store.UpdateUser(id, store.UserUpdate{
+		Name:         req.Name,
+		Nickname:     req.Nickname,
+		WalkOnColour: req.WalkOnColour,
+		Preferences:  req.Preferences,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, store.ErrDuplicateUsername):
+			writeError(w, http.StatusConflict, "Username already exists")
+		default:
+			log.Printf("Failed to update user %d: %v", id, err)
+			writeError(w, http.StatusInternalServerError, "Failed to update user")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
 func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -108,6 +252,10 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "User not found")
 			return
 		}
+		if errors.Is(err, store.ErrUserInActiveGame) {
+			writeError(w, http.StatusConflict, "User is playing an unfinished game")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
@@ -115,6 +263,26 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// UnarchiveUser returns an archived player to the player list
+func (h *Handler) UnarchiveUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.store.UnarchiveUser(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to unarchive user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Game Handlers
 func (h *Handler) CreateGame(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -144,6 +312,10 @@ func (h *Handler) CreateGame(w http.ResponseWriter, r *http.Request) {
 
 	g, err := h.store.CreateGame(req.TotalPoints, req.BestOf, req.DoubleOut, req.PlayerIDs)
 	if err != nil {
+		if errors.Is(err, store.ErrUnknownPlayer) {
+			writeError(w, http.StatusBadRequest, "Unknown or archived player")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "Failed to create game")
 		return
 	}
@@ -168,7 +340,25 @@ func (h *Handler) GetGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, g)
+	live, err := h.store.GetLiveStats(g)
+	if err != nil {
+		log.Printf("Failed to get live statistics for game %d: %v", id, err)
+	}
+
+	writeJSON(w, http.StatusOK, gameResponse{Game: g, LiveStats: live})
+}
+
+// gameResponse is the game state plus the running summary of every player
+type gameResponse struct {
+	*models.Game
+	LiveStats []store.LivePlayerStats `json:"live_stats,omitempty"`
+}
+
+// throwResponse is the game state after a throw plus the achievements the
+// throw unlocked
+type throwResponse struct {
+	gameResponse
+	NewAchievements []models.Achievement `json:"new_achievements,omitempty"`
 }
 
 func (h *Handler) HandleThrow(w http.ResponseWriter, r *http.Request) {
@@ -212,21 +402,25 @@ func (h *Handler) HandleThrow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 3. Save Throw
-	if err := h.store.SaveThrow(throw); err != nil {
+	// 3. Save Throw and Game State in one transaction, which also rates
+	// the players once the game is finished and unlocks achievements
+	achievements, err := h.store.RecordThrow(throw, g)
+	if err != nil {
 		log.Printf("Failed to save throw for game %d: %v", id, err)
 		writeError(w, http.StatusInternalServerError, "Failed to save throw")
 		return
 	}
 
-	// 4. Update Game State in DB
-	if err := h.store.UpdateGame(g); err != nil {
-		log.Printf("Failed to update game %d: %v", id, err)
-		writeError(w, http.StatusInternalServerError, "Failed to update game state")
-		return
+	// 4. Running summary for the scoreboard, only logged on failure
+	live, err := h.store.GetLiveStats(g)
+	if err != nil {
+		log.Printf("Failed to get live statistics for game %d: %v", id, err)
 	}
 
-	writeJSON(w, http.StatusOK, g)
+	writeJSON(w, http.StatusOK, throwResponse{
+		gameResponse:    gameResponse{Game: g, LiveStats: live},
+		NewAchievements: achievements,
+	})
 }
 
 func (h *Handler) GetUserStats(w http.ResponseWriter, r *http.Request) {
@@ -254,7 +448,7 @@ func (h *Handler) GetGameStatistics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify game exists and is finished
+	// Verify game exists; unfinished games get live statistics
 	game, err := h.store.GetGame(id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to get game")
@@ -265,7 +459,7 @@ func (h *Handler) GetGameStatistics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats, err := h.store.GetGameStatistics(id)
+	stats, err := h.store.GetGameStatistics(game)
 	if err != nil {
 		log.Printf("Failed to calculate statistics for game %d: %v", id, err)
 		writeError(w, http.StatusInternalServerError, "Failed to calculate statistics")
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/avatar"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// testAdminToken is the ADMIN_TOKEN of the test server
+const testAdminToken = "test-admin-token"
+
+// newTestServer routes the handlers like the server does, backed by the
+// test store (see store.NewTestStore)
+func newTestServer(t *testing.T) *http.ServeMux {
+	t.Helper()
+	s, err := store.NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	avatars := avatar.NewStore(t.TempDir())
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, "/api", NewHandler(s), NewAvatarHandler(s, avatars), NewAdminHandler(s, nil, avatars),
+		NewAuthHandler(s, AuthConfig{AdminToken: testAdminToken, SessionTTL: time.Hour, CookiePath: "/"}))
+	return mux
+}
+
+// A credential replaces the admin token that requests are sent with
+type credential func(*http.Request)
+
+// anonymous sends a request without credentials
+func anonymous(r *http.Request) {
+	r.Header.Del("Authorization")
+}
+
+// withBearer sends a request with an API token
+func withBearer(token string) credential {
+	return func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// withCookie sends a request with a session cookie only
+func withCookie(c *http.Cookie) credential {
+	return func(r *http.Request) {
+		r.Header.Del("Authorization")
+		r.AddCookie(c)
+	}
+}
+
+// serve sends a request with an optional JSON body, as admin unless a
+// credential says otherwise
+func serve(t *testing.T, mux *http.ServeMux, method, path string, body interface{}, creds ...credential) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("Failed to encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	for _, c := range creds {
+		c(req)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// do sends a request like serve and decodes the JSON response into out, if
+// given
+func do(t *testing.T, mux *http.ServeMux, method, path string, body, out interface{}, creds ...credential) int {
+	t.Helper()
+	rec := serve(t, mux, method, path, body, creds...)
+	if out != nil && rec.Code < 300 {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+func createTestUser(t *testing.T, mux *http.ServeMux, name string) models.User {
+	t.Helper()
+	var u models.User
+	if code := do(t, mux, "POST", "/api/users", map[string]string{"name": name}, &u); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating %s, got %d", name, code)
+	}
+	return u
+}
+
+func TestCreateUser(t *testing.T) {
+	mux := newTestServer(t)
+
+	alice := createTestUser(t, mux, "Alice")
+	if alice.ID == 0 || alice.Name != "Alice" {
+		t.Errorf("Expected Alice with an ID, got %+v", alice)
+	}
+
+	if code := do(t, mux, "POST", "/api/users", map[string]string{"name": "Alice"}, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate name, got %d", code)
+	}
+	if code := do(t, mux, "POST", "/api/users", map[string]string{"name": " alice "}, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a name differing in case, got %d", code)
+	}
+	if code := do(t, mux, "POST", "/api/users", map[string]string{"name": "   "}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a blank name, got %d", code)
+	}
+
+	var users []models.User
+	if code := do(t, mux, "GET", "/api/users", nil, &users); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(users) != 1 {
+		t.Errorf("Expected 1 user, got %d", len(users))
+	}
+}
+
+func TestListUsers_Search(t *testing.T) {
+	mux := newTestServer(t)
+	for _, name := range []string{"Alice", "Alina", "Bob"} {
+		createTestUser(t, mux, name)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users?q=ali&limit=1&offset=1", nil))
+	var users []models.User
+	if err := json.NewDecoder(rec.Body).Decode(&users); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Total-Count") != "2" || len(users) != 1 || users[0].Name != "Alina" {
+		t.Errorf("Expected Alina of 2 matches, got %d %s %+v", rec.Code, rec.Header().Get("X-Total-Count"), users)
+	}
+
+	for _, query := range []string{"limit=0", "limit=1000", "offset=-1", "limit=x"} {
+		if code := do(t, mux, "GET", "/api/users?"+query, nil, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, code)
+		}
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+	bob := createTestUser(t, mux, "Bob")
+	game := map[string]interface{}{"total_points": 301, "best_of": 1, "player_ids": []int{bob.ID}}
+	if code := do(t, mux, "POST", "/api/games", game, nil); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a game, got %d", code)
+	}
+
+	alicePath := "/api/users/" + strconv.Itoa(alice.ID)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/users/abc", http.StatusBadRequest},
+		{"/api/users/99", http.StatusNotFound},
+		{"/api/users/" + strconv.Itoa(bob.ID), http.StatusConflict},
+		{alicePath, http.StatusNoContent},
+		{alicePath, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := do(t, mux, "DELETE", tt.path, nil, nil); code != tt.want {
+			t.Errorf("DELETE %s: expected %d, got %d", tt.path, tt.want, code)
+		}
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+	createTestUser(t, mux, "Bob")
+	path := "/api/users/" + strconv.Itoa(alice.ID)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want int
+	}{
+		{"duplicate", map[string]interface{}{"name": "Bob"}, http.StatusConflict},
+		{"empty name", map[string]interface{}{"name": ""}, http.StatusBadRequest},
+		{"colour", map[string]interface{}{"walk_on_colour": "red"}, http.StatusBadRequest},
+		{"points", map[string]interface{}{"preferences": map[string]int{"total_points": 401}}, http.StatusBadRequest},
+		{"best of", map[string]interface{}{"preferences": map[string]int{"best_of": 2}}, http.StatusBadRequest},
+		{"rename", map[string]interface{}{"name": "Alicia", "nickname": "The Arrow"}, http.StatusOK},
+		{"profile", map[string]interface{}{
+			"walk_on_colour": "#ff8800",
+			"preferences":    map[string]interface{}{"total_points": 301, "best_of": 3, "double_out": false},
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := do(t, mux, "PATCH", path, tt.body, nil); code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+	if code := do(t, mux, "PATCH", "/api/users/99", map[string]string{"nickname": "Nobody"}, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing user, got %d", code)
+	}
+
+	var u models.User
+	if code := do(t, mux, "GET", path, nil, &u); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	p := u.Preferences
+	if u.Name != "Alicia" || u.Nickname != "The Arrow" || u.WalkOnColour != "#ff8800" ||
+		p.TotalPoints != 301 || p.BestOf != 3 || p.DoubleOut == nil || *p.DoubleOut {
+		t.Errorf("Expected the updated profile, got %+v", u)
+	}
+	if code := do(t, mux, "GET", "/api/users/99", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing user, got %d", code)
+	}
+}
+
+// uploadAvatar sends data as the avatar file of a multipart form
+func uploadAvatar(t *testing.T, mux *http.ServeMux, path string, data []byte) int {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatalf("Failed to create form: %v", err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("PUT", path, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestUploadAvatar(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+	path := "/api/users/" + strconv.Itoa(alice.ID) + "/avatar"
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	if code := do(t, mux, "GET", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 without an avatar, got %d", code)
+	}
+	if code := uploadAvatar(t, mux, path, []byte("GIF89a")); code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for a GIF, got %d", code)
+	}
+	if code := uploadAvatar(t, mux, path, append(png, make([]byte, avatar.MaxSize)...)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large picture, got %d", code)
+	}
+	if code := uploadAvatar(t, mux, "/api/users/99/avatar", png); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing user, got %d", code)
+	}
+	if code := uploadAvatar(t, mux, path, png); code != http.StatusOK {
+		t.Fatalf("Expected 200 uploading, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rec.Body.Bytes(), png) {
+		t.Errorf("Expected the PNG back, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	if code := do(t, mux, "DELETE", path, nil, nil); code != http.StatusNoContent {
+		t.Errorf("Expected 204 deleting, got %d", code)
+	}
+	if code := do(t, mux, "GET", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 after deleting, got %d", code)
+	}
+}
+
+func TestPurgeUser(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+	path := "/api/admin/users/" + strconv.Itoa(alice.ID) + "/purge"
+
+	tests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"unconfirmed", map[string]string{"mode": "delete", "confirm": "alice"}, http.StatusBadRequest},
+		{"mode", map[string]string{"mode": "forget", "confirm": "Alice"}, http.StatusBadRequest},
+		{"anonymise", map[string]string{"mode": "anonymise", "confirm": "Alice"}, http.StatusOK},
+		{"renamed", map[string]string{"mode": "delete", "confirm": "Alice"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := do(t, mux, "POST", path, tt.body, nil); code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+
+	var users []models.User
+	if code := do(t, mux, "GET", "/api/users?archived=true", nil, &users); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(users) != 1 || users[0].Name == "Alice" {
+		t.Errorf("Expected the anonymised player in the archive, got %+v", users)
+	}
+	if code := do(t, mux, "POST", "/api/users/"+strconv.Itoa(alice.ID)+"/unarchive", nil, nil); code != http.StatusNoContent {
+		t.Errorf("Expected 204 unarchiving, got %d", code)
+	}
+}
+
+func TestMergeUsers(t *testing.T) {
+	mux := newTestServer(t)
+	mike := createTestUser(t, mux, "Mike")
+	michael := createTestUser(t, mux, "Michael")
+	game := map[string]interface{}{"total_points": 301, "best_of": 1, "player_ids": []int{michael.ID}}
+	if code := do(t, mux, "POST", "/api/games", game, nil); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a game, got %d", code)
+	}
+	path := "/api/admin/users/" + strconv.Itoa(mike.ID) + "/merge"
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want int
+	}{
+		{"same user", map[string]interface{}{"from": mike.ID}, http.StatusBadRequest},
+		{"missing user", map[string]interface{}{"from": 99}, http.StatusNotFound},
+		{"active game", map[string]interface{}{"from": michael.ID, "dry_run": true}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if code := do(t, mux, "POST", path, tt.body, nil); code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+
+	mikeS := createTestUser(t, mux, "mike s")
+	var report store.MergeReport
+	if code := do(t, mux, "POST", path, map[string]interface{}{"from": mikeS.ID}, &report); code != http.StatusOK {
+		t.Fatalf("Expected 200 merging, got %d", code)
+	}
+	var users []models.User
+	do(t, mux, "GET", "/api/users?archived=true", nil, &users)
+	if len(users) != 1 || users[0].ID != mikeS.ID {
+		t.Errorf("Expected the merged player in the archive, got %+v", users)
+	}
+}
+
+func TestCreateGame_Validation(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want int
+	}{
+		{"valid", map[string]interface{}{"total_points": 301, "best_of": 1, "player_ids": []int{alice.ID}}, http.StatusCreated},
+		{"points", map[string]interface{}{"total_points": 401, "best_of": 1, "player_ids": []int{alice.ID}}, http.StatusBadRequest},
+		{"best of", map[string]interface{}{"total_points": 301, "best_of": 2, "player_ids": []int{alice.ID}}, http.StatusBadRequest},
+		{"no players", map[string]interface{}{"total_points": 301, "best_of": 1, "player_ids": []int{}}, http.StatusBadRequest},
+		{"unknown player", map[string]interface{}{"total_points": 301, "best_of": 1, "player_ids": []int{99}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := do(t, mux, "POST", "/api/games", tt.body, nil); code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+
+	if code := do(t, mux, "GET", "/api/games/42", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing game, got %d", code)
+	}
+}
+
+func TestHandleThrow_PlaysGame(t *testing.T) {
+	mux := newTestServer(t)
+	alice := createTestUser(t, mux, "Alice")
+	bob := createTestUser(t, mux, "Bob")
+
+	var g models.Game
+	body := map[string]interface{}{"total_points": 301, "best_of": 1, "double_out": true, "player_ids": []int{alice.ID, bob.ID}}
+	if code := do(t, mux, "POST", "/api/games", body, &g); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	throwPath := "/api/games/" + strconv.Itoa(g.ID) + "/throw"
+
+	if code := do(t, mux, "POST", throwPath, map[string]int{"user_id": bob.ID, "points": 20, "multiplier": 1}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a throw out of turn, got %d", code)
+	}
+	if code := do(t, mux, "POST", throwPath, map[string]int{"user_id": alice.ID, "points": 21, "multiplier": 1}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid segment, got %d", code)
+	}
+
+	// Alice: 180, 81 (-> 40), D20. Bob scores 60 per visit in between.
+	darts := []struct{ user, points, multiplier int }{
+		{alice.ID, 20, 3}, {alice.ID, 20, 3}, {alice.ID, 20, 3},
+		{bob.ID, 20, 1}, {bob.ID, 20, 1}, {bob.ID, 20, 1},
+		{alice.ID, 20, 3}, {alice.ID, 7, 3}, {alice.ID, 0, 1},
+		{bob.ID, 20, 1}, {bob.ID, 20, 1}, {bob.ID, 20, 1},
+		{alice.ID, 20, 2},
+	}
+	var resp struct {
+		models.Game
+		LiveStats []store.LivePlayerStats `json:"live_stats"`
+	}
+	for i, d := range darts {
+		body := map[string]int{"user_id": d.user, "points": d.points, "multiplier": d.multiplier}
+		if code := do(t, mux, "POST", throwPath, body, &resp); code != http.StatusOK {
+			t.Fatalf("Dart %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if resp.Status != models.GameStatusFinished || resp.WinnerID == nil || *resp.WinnerID != alice.ID {
+		t.Fatalf("Expected Alice to win, got status %s winner %v", resp.Status, resp.WinnerID)
+	}
+	if len(resp.LiveStats) != 2 {
+		t.Errorf("Expected live stats for 2 players, got %d", len(resp.LiveStats))
+	}
+
+	if code := do(t, mux, "POST", throwPath, map[string]int{"user_id": bob.ID, "points": 20, "multiplier": 1}, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a throw after the game, got %d", code)
+	}
+
+	var stats store.UserStats
+	if code := do(t, mux, "GET", "/api/users/"+strconv.Itoa(alice.ID)+"/stats", nil, &stats); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if stats.TotalGames != 1 || stats.Wins != 1 || stats.Maximums180 != 1 {
+		t.Errorf("Expected 1 game, 1 win and one 180, got %+v", stats)
+	}
+
+	var gameStats store.GameStatistics
+	if code := do(t, mux, "GET", "/api/games/"+strconv.Itoa(g.ID)+"/statistics", nil, &gameStats); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+}
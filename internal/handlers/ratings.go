@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) GetRatings(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.store.GetRatingLeaderboard()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get ratings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (h *Handler) GetRatingHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	history, err := h.store.GetRatingHistory(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get rating history")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/michaelschlottmann/darts-web/internal/report"
+)
+
+func (h *Handler) GetGameReport(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	timeline, err := h.store.GetGameTimeline(id)
+	if err != nil {
+		log.Printf("Failed to build timeline for game %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+	if timeline == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	g, err := h.store.GetGame(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+	if g == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	stats, err := h.store.GetGameStatistics(g)
+	if err != nil {
+		log.Printf("Failed to calculate statistics for game %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+
+	// Render completely before writing, so a failure can still be reported
+	var buf bytes.Buffer
+	if err := report.WriteGamePDF(&buf, timeline, stats); err != nil {
+		log.Printf("Failed to render report for game %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="game-%d-report.pdf"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import "net/http"
+
+// RegisterRoutes registers the API routes under prefix, e.g. "/darts/api".
+// Reading is open to everyone, scoring needs the scorer role and deleting or
+// maintenance the admin role.
+func RegisterRoutes(mux *http.ServeMux, prefix string, h *Handler, avatars *AvatarHandler, admin *AdminHandler, a *AuthHandler) {
+	mux.HandleFunc("GET "+prefix+"/users", h.ListUsers)
+	mux.HandleFunc("POST "+prefix+"/users", a.RequireScorer(h.CreateUser))
+	mux.HandleFunc("GET "+prefix+"/users/{id}", h.GetUser)
+	mux.HandleFunc("PATCH "+prefix+"/users/{id}", a.RequireScorer(h.UpdateUser))
+	mux.HandleFunc("DELETE "+prefix+"/users/{id}", a.RequireAdmin(h.DeleteUser))
+	mux.HandleFunc("GET "+prefix+"/users/{id}/avatar", avatars.GetAvatar)
+	mux.HandleFunc("PUT "+prefix+"/users/{id}/avatar", a.RequireScorer(avatars.UploadAvatar))
+	mux.HandleFunc("DELETE "+prefix+"/users/{id}/avatar", a.RequireScorer(avatars.DeleteAvatar))
+	mux.HandleFunc("POST "+prefix+"/users/{id}/unarchive", a.RequireAdmin(h.UnarchiveUser))
+	mux.HandleFunc("GET "+prefix+"/users/{id}/stats", h.GetUserStats)
+	mux.HandleFunc("GET "+prefix+"/users/{id}/heatmap", h.GetUserHeatmap)
+	mux.HandleFunc("GET "+prefix+"/users/{id}/stats/timeseries", h.GetUserTimeSeries)
+	mux.HandleFunc("GET "+prefix+"/users/{id}/vs/{otherId}", h.GetHeadToHead)
+	mux.HandleFunc("GET "+prefix+"/users/{id}/rating/history", h.GetRatingHistory)
+	mux.HandleFunc("GET "+prefix+"/users/{id}/achievements", h.GetAchievements)
+	mux.HandleFunc("GET "+prefix+"/ratings", h.GetRatings)
+	mux.HandleFunc("GET "+prefix+"/leaderboards", h.GetLeaderboard)
+	mux.HandleFunc("GET "+prefix+"/export/throws.csv", h.ExportThrowsCSV)
+	mux.HandleFunc("GET "+prefix+"/export/games.json", h.ExportGamesJSON)
+	mux.HandleFunc("POST "+prefix+"/games", a.RequireScorer(h.CreateGame))
+	mux.HandleFunc("GET "+prefix+"/games/{id}/statistics", h.GetGameStatistics)
+	mux.HandleFunc("GET "+prefix+"/games/{id}/timeline", h.GetGameTimeline)
+	mux.HandleFunc("GET "+prefix+"/games/{id}/report.pdf", h.GetGameReport)
+	mux.HandleFunc("GET "+prefix+"/games/{id}", h.GetGame)
+	mux.HandleFunc("POST "+prefix+"/games/{id}/throw", a.RequireScorer(h.HandleThrow))
+	mux.HandleFunc("GET "+prefix+"/admin/backups/latest", a.RequireAdmin(admin.DownloadLatestBackup))
+	mux.HandleFunc("POST "+prefix+"/admin/import", a.RequireAdmin(admin.Import))
+	mux.HandleFunc("POST "+prefix+"/admin/users/{id}/purge", a.RequireAdmin(admin.PurgeUser))
+	mux.HandleFunc("POST "+prefix+"/admin/users/{id}/merge", a.RequireAdmin(admin.MergeUsers))
+	mux.HandleFunc("GET "+prefix+"/admin/accounts", a.RequireAdmin(a.ListAccounts))
+	mux.HandleFunc("POST "+prefix+"/admin/accounts", a.RequireAdmin(a.CreateAccount))
+	mux.HandleFunc("DELETE "+prefix+"/admin/accounts/{id}", a.RequireAdmin(a.DeleteAccount))
+	mux.HandleFunc("PUT "+prefix+"/admin/accounts/{id}/password", a.RequireAdmin(a.SetAccountPassword))
+	mux.HandleFunc("GET "+prefix+"/admin/tokens", a.RequireAdmin(a.ListAPITokens))
+	mux.HandleFunc("POST "+prefix+"/admin/tokens", a.RequireAdmin(a.CreateAPIToken))
+	mux.HandleFunc("DELETE "+prefix+"/admin/tokens/{id}", a.RequireAdmin(a.DeleteAPIToken))
+	mux.HandleFunc("POST "+prefix+"/auth/login", a.Login)
+	mux.HandleFunc("POST "+prefix+"/auth/logout", a.Logout)
+	mux.HandleFunc("GET "+prefix+"/auth/me", a.Me)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// parseTimeParam parses a query parameter given as a date (2006-01-02) or
+// RFC 3339 timestamp. With endOfDay set, a plain date covers the whole day.
+func parseTimeParam(r *http.Request, name string, endOfDay bool) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseThrowFilter reads the game_id, from and to query parameters
+func parseThrowFilter(r *http.Request) (store.ThrowFilter, error) {
+	var filter store.ThrowFilter
+	var err error
+
+	if gameIDStr := r.URL.Query().Get("game_id"); gameIDStr != "" {
+		filter.GameID, err = strconv.Atoi(gameIDStr)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if filter.From, err = parseTimeParam(r, "from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeParam(r, "to", true); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func (h *Handler) GetUserHeatmap(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	filter, err := parseThrowFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid filter: use game_id and from/to as YYYY-MM-DD or RFC 3339")
+		return
+	}
+
+	heatmap, err := h.store.GetUserHeatmap(id, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get heatmap")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, heatmap)
+}
+
+func (h *Handler) GetUserTimeSeries(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "week"
+	}
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = store.MetricAverage3Dart
+	}
+
+	filter, err := parseThrowFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid filter: use game_id and from/to as YYYY-MM-DD or RFC 3339")
+		return
+	}
+
+	series, err := h.store.GetUserTimeSeries(id, metric, bucket, filter)
+	if err != nil {
+		if errors.Is(err, store.ErrUnknownBucket) {
+			writeError(w, http.StatusBadRequest, "Bucket must be day, week or month")
+			return
+		}
+		if errors.Is(err, store.ErrUnknownMetric) {
+			writeError(w, http.StatusBadRequest, "Metric must be average_3_dart, checkout_percentage, games_played or win_rate")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to get statistics over time")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, series)
+}
+
+// headToHeadRecentResults is the number of latest shared games returned
+const headToHeadRecentResults = 10
+
+func (h *Handler) GetHeadToHead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	otherID, err := strconv.Atoi(r.PathValue("otherId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid opponent ID")
+		return
+	}
+	if id == otherID {
+		writeError(w, http.StatusBadRequest, "Cannot compare a player with themselves")
+		return
+	}
+
+	for _, uid := range []int{id, otherID} {
+		user, err := h.store.GetUser(uid)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get user")
+			return
+		}
+		if user == nil {
+			writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+	}
+
+	h2h, err := h.store.GetHeadToHead(id, otherID, headToHeadRecentResults)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get head-to-head record")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h2h)
+}
+
+// defaultLeaderboardMinGames keeps a single lucky game off the top
+const defaultLeaderboardMinGames = 5
+
+// parsePeriod converts a period like "30d" into the start time, or the zero
+// time for "all"
+func parsePeriod(period string) (time.Time, error) {
+	if period == "" || period == "all" {
+		return time.Time{}, nil
+	}
+	if !strings.HasSuffix(period, "d") {
+		return time.Time{}, errors.New("invalid period")
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(period, "d"))
+	if err != nil || days < 1 {
+		return time.Time{}, errors.New("invalid period")
+	}
+	return time.Now().AddDate(0, 0, -days), nil
+}
+
+func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = store.MetricAverage3Dart
+	}
+
+	since, err := parsePeriod(r.URL.Query().Get("period"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Period must be a number of days like 30d, or all")
+		return
+	}
+
+	minGames := defaultLeaderboardMinGames
+	if minGamesStr := r.URL.Query().Get("min_games"); minGamesStr != "" {
+		minGames, err = strconv.Atoi(minGamesStr)
+		if err != nil || minGames < 1 {
+			writeError(w, http.StatusBadRequest, "min_games must be a positive number")
+			return
+		}
+	}
+
+	entries, err := h.store.GetLeaderboard(metric, since, minGames)
+	if err != nil {
+		if errors.Is(err, store.ErrUnknownMetric) {
+			writeError(w, http.StatusBadRequest, "Metric must be average_3_dart, win_rate, maximums_180, highest_checkout or checkout_percentage")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to get leaderboard")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"metric":    metric,
+		"min_games": minGames,
+		"entries":   entries,
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+func (h *Handler) GetGameTimeline(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	timeline, err := h.store.GetGameTimeline(id)
+	if err != nil {
+		log.Printf("Failed to build timeline for game %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to get timeline")
+		return
+	}
+	if timeline == nil {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, timeline)
+}
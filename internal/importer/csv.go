@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// requiredCSVColumns must be present in the header of a CSV archive. The
+// optional columns are multiplier, played_at, thrown_at, total_points,
+// best_of, double_out and players.
+var requiredCSVColumns = []string{"game", "player", "points"}
+
+// csvGame collects the rows of one game of a CSV archive
+type csvGame struct {
+	game    store.ImportGame
+	failed  bool
+	players bool // Players were given explicitly
+}
+
+// readCSV parses a CSV archive with one dart per row. Rows are grouped into
+// games by the game column; the game settings are taken from the first row
+// of a game that has them. Row numbers are line numbers, the header being 1.
+func readCSV(r io.Reader) (*Archive, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV archive: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("invalid CSV archive: missing column %q", name)
+		}
+	}
+
+	archive := &Archive{}
+	games := make(map[string]*csvGame)
+	var order []string
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("invalid CSV archive: %w", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		ref := field("game")
+		if ref == "" {
+			archive.Errors = append(archive.Errors, store.ImportError{Game: "", Row: line, Message: "missing game"})
+			continue
+		}
+		cg, ok := games[ref]
+		if !ok {
+			cg = &csvGame{game: store.ImportGame{Ref: ref}}
+			games[ref] = cg
+			order = append(order, ref)
+		}
+
+		if err := cg.addRow(line, field); err != nil {
+			cg.failed = true
+			archive.Errors = append(archive.Errors, store.ImportError{Game: ref, Row: line, Message: err.Error()})
+		}
+	}
+
+	for _, ref := range order {
+		if cg := games[ref]; !cg.failed {
+			archive.Games = append(archive.Games, cg.game)
+		}
+	}
+	return archive, nil
+}
+
+// addRow applies the settings of a row to the game and appends its throw
+func (cg *csvGame) addRow(line int, field func(string) string) error {
+	g := &cg.game
+
+	if v := field("total_points"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid total_points %q", v)
+		}
+		if g.TotalPoints != 0 && g.TotalPoints != n {
+			return fmt.Errorf("total_points %d differs from %d in an earlier row", n, g.TotalPoints)
+		}
+		g.TotalPoints = n
+	}
+	if v := field("best_of"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid best_of %q", v)
+		}
+		if g.BestOf != 0 && g.BestOf != n {
+			return fmt.Errorf("best_of %d differs from %d in an earlier row", n, g.BestOf)
+		}
+		g.BestOf = n
+	}
+	if v := field("double_out"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid double_out %q", v)
+		}
+		g.DoubleOut = b
+	}
+	if v := field("played_at"); v != "" && g.PlayedAt.IsZero() {
+		t, err := parseTime(v)
+		if err != nil {
+			return fmt.Errorf("invalid played_at %q", v)
+		}
+		g.PlayedAt = t
+	}
+	if v := field("players"); v != "" && !cg.players {
+		g.Players = strings.Split(v, ";")
+		cg.players = true
+	}
+
+	throw := store.ImportThrow{Row: line, Player: field("player")}
+	if throw.Player == "" {
+		return errors.New("missing player")
+	}
+	var err error
+	if throw.Points, err = strconv.Atoi(field("points")); err != nil {
+		return fmt.Errorf("invalid points %q", field("points"))
+	}
+	throw.Multiplier = 1
+	if v := field("multiplier"); v != "" {
+		if throw.Multiplier, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid multiplier %q", v)
+		}
+	}
+	if v := field("thrown_at"); v != "" {
+		if throw.ThrownAt, err = parseTime(v); err != nil {
+			return fmt.Errorf("invalid thrown_at %q", v)
+		}
+	}
+
+	// Without a players column the throwing order is the order of appearance
+	if !cg.players && !slices.Contains(g.Players, throw.Player) {
+		g.Players = append(g.Players, throw.Player)
+	}
+	g.Throws = append(g.Throws, throw)
+	return nil
+}
+
+// parseTime accepts a date (2006-01-02) or an RFC 3339 timestamp
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
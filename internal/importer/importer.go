@@ -0,0 +1,197 @@
+// Package importer imports archives of games and throws kept outside of the
+// app, e.g. in spreadsheets or other scoring apps. Archives are JSON or CSV;
+// the formats are described in the README. Every game is replayed through
+// the game engine and stored in one transaction, so a game with an invalid
+// row is rejected as a whole while the other games are still imported.
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// Format is the file format of an archive
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// Archive is a parsed archive. Games that could not be parsed are left out
+// and reported in Errors.
+type Archive struct {
+	Games  []store.ImportGame
+	Errors []store.ImportError
+}
+
+// Report is the outcome of an import
+type Report struct {
+	GamesImported int                 `json:"games_imported"`
+	GamesFailed   int                 `json:"games_failed"`
+	GameIDs       []int               `json:"game_ids"`
+	Errors        []store.ImportError `json:"errors"`
+}
+
+// jsonArchive is the JSON archive format
+type jsonArchive struct {
+	Games []struct {
+		Ref         string    `json:"ref"`
+		PlayedAt    time.Time `json:"played_at"`
+		TotalPoints int       `json:"total_points"`
+		BestOf      int       `json:"best_of"`
+		DoubleOut   bool      `json:"double_out"`
+		Players     []string  `json:"players"`
+		Throws      []struct {
+			Player     string    `json:"player"`
+			Points     int       `json:"points"`
+			Multiplier int       `json:"multiplier"`
+			ThrownAt   time.Time `json:"thrown_at"`
+		} `json:"throws"`
+	} `json:"games"`
+}
+
+// Read parses an archive in the given format
+func Read(r io.Reader, format Format) (*Archive, error) {
+	switch format {
+	case FormatJSON:
+		return readJSON(r)
+	case FormatCSV:
+		return readCSV(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func readJSON(r io.Reader) (*Archive, error) {
+	var in jsonArchive
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("invalid JSON archive: %w", err)
+	}
+
+	archive := &Archive{}
+	for i, g := range in.Games {
+		ig := store.ImportGame{
+			Ref:         g.Ref,
+			PlayedAt:    g.PlayedAt,
+			TotalPoints: g.TotalPoints,
+			BestOf:      g.BestOf,
+			DoubleOut:   g.DoubleOut,
+			Players:     g.Players,
+		}
+		if ig.Ref == "" {
+			ig.Ref = fmt.Sprintf("#%d", i+1)
+		}
+		// Rows are the 1-based positions in the throws array
+		for j, t := range g.Throws {
+			ig.Throws = append(ig.Throws, store.ImportThrow{
+				Row:        j + 1,
+				Player:     t.Player,
+				Points:     t.Points,
+				Multiplier: defaultMultiplier(t.Multiplier),
+				ThrownAt:   t.ThrownAt,
+			})
+		}
+		archive.Games = append(archive.Games, ig)
+	}
+	return archive, nil
+}
+
+// defaultMultiplier treats a missing multiplier as a single
+func defaultMultiplier(m int) int {
+	if m == 0 {
+		return 1
+	}
+	return m
+}
+
+// validateGame checks the game settings with the same rules as creating a
+// game through the API. The throws are validated by the engine.
+func validateGame(ig *store.ImportGame) *store.ImportError {
+	fail := func(format string, args ...interface{}) *store.ImportError {
+		return &store.ImportError{Game: ig.Ref, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if ig.TotalPoints != 301 && ig.TotalPoints != 501 {
+		return fail("total points must be 301 or 501")
+	}
+	if ig.BestOf != 1 && ig.BestOf != 3 && ig.BestOf != 5 {
+		return fail("best of must be 1, 3, or 5")
+	}
+	if len(ig.Players) < 1 || len(ig.Players) > 4 {
+		return fail("number of players must be between 1 and 4")
+	}
+	seen := make(map[string]bool)
+	for i, name := range ig.Players {
+		name = store.NormaliseName(name)
+		if name == "" {
+			return fail("player names must not be empty")
+		}
+		if seen[name] {
+			return fail("player %q is listed twice", name)
+		}
+		seen[name] = true
+		ig.Players[i] = name
+	}
+	if len(ig.Throws) == 0 {
+		return fail("game has no throws")
+	}
+	for i := range ig.Throws {
+		ig.Throws[i].Player = store.NormaliseName(ig.Throws[i].Player)
+	}
+	return nil
+}
+
+// Import validates and stores every game of the archive. Games with errors
+// are reported and skipped; only database failures abort the import. The
+// ratings are recomputed afterwards, because imported games may predate
+// games that were already rated.
+func Import(s store.Store, archive *Archive) (*Report, error) {
+	report := &Report{
+		GameIDs: []int{},
+		Errors:  append([]store.ImportError{}, archive.Errors...),
+	}
+	failed := make(map[string]bool)
+	for _, e := range archive.Errors {
+		// Rows without a game don't belong to any game
+		if e.Game != "" {
+			failed[e.Game] = true
+		}
+	}
+
+	for i := range archive.Games {
+		ig := &archive.Games[i]
+		if err := validateGame(ig); err != nil {
+			report.Errors = append(report.Errors, *err)
+			failed[ig.Ref] = true
+			continue
+		}
+
+		g, err := s.ImportGame(ig)
+		var importErr *store.ImportError
+		if errors.As(err, &importErr) {
+			report.Errors = append(report.Errors, *importErr)
+			failed[ig.Ref] = true
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("game %s: %w", ig.Ref, err)
+		}
+		report.GamesImported++
+		report.GameIDs = append(report.GameIDs, g.ID)
+	}
+	report.GamesFailed = len(failed)
+
+	if report.GamesImported > 0 {
+		if _, err := s.RecomputeRatings(); err != nil {
+			return report, fmt.Errorf("recompute ratings: %w", err)
+		}
+	}
+	return report, nil
+}
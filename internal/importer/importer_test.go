@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+const testCSV = `game,played_at,total_points,best_of,double_out,player,points,multiplier
+league-1,2019-03-01,301,1,true,Alice,20,3
+league-1,,,,,Alice,20,3
+league-1,,,,,Alice,20,3
+league-1,,,,,Bob,20,1
+league-1,,,,,Bob,20,1
+league-1,,,,,Bob,20,1
+league-1,,,,,Alice,20,3
+league-1,,,,,Alice,11,3
+league-1,,,,,Alice,14,2
+league-2,2019-03-08,301,1,true,Carol,20,3
+league-2,,,,,Bob,20,3
+league-2,,,,,Carol,20,3
+league-2,,,,,Carol,20,3
+league-3,2019-03-15,301,1,true,Alice,x,1
+`
+
+func TestImport_CSV(t *testing.T) {
+	s, err := store.NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	// Existing players are matched by name
+	alice, err := s.CreateUser("Alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	archive, err := Read(strings.NewReader(testCSV), FormatCSV)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	report, err := Import(s, archive)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+
+	if report.GamesImported != 1 || report.GamesFailed != 2 {
+		t.Errorf("Expected 1 imported and 2 failed games, got %d and %d", report.GamesImported, report.GamesFailed)
+	}
+	if len(report.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %+v", report.Errors)
+	}
+	// The parse error is reported first, then the engine rejects Bob's dart
+	// during Carol's visit
+	if e := report.Errors[0]; e.Game != "league-3" || e.Row != 15 {
+		t.Errorf("Expected error in row 15 of league-3, got %+v", e)
+	}
+	if e := report.Errors[1]; e.Game != "league-2" || e.Row != 12 {
+		t.Errorf("Expected error in row 12 of league-2, got %+v", e)
+	}
+
+	// The failed game left no trace, not even its new player
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 2 || users[0].ID != alice.ID || users[1].Name != "Bob" {
+		t.Errorf("Expected Alice and Bob only, got %+v", users)
+	}
+
+	g, err := s.GetGame(report.GameIDs[0])
+	if err != nil {
+		t.Fatalf("Failed to load game: %v", err)
+	}
+	if g.Status != models.GameStatusFinished || g.WinnerID == nil || *g.WinnerID != alice.ID {
+		t.Errorf("Expected Alice to have won the imported game, got %+v", g)
+	}
+	if want := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC); !g.CreatedAt.Equal(want) {
+		t.Errorf("Expected game played at %v, got %v", want, g.CreatedAt)
+	}
+
+	// Statistics and ratings include the imported game
+	stats, err := s.GetUserStats(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user stats: %v", err)
+	}
+	if stats.TotalGames != 1 || stats.Wins != 1 || stats.HighestCheckout != 121 {
+		t.Errorf("Expected 1 won game with a 121 checkout, got %+v", stats)
+	}
+	history, err := s.GetRatingHistory(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get rating history: %v", err)
+	}
+	if len(history) != 1 || history[0].Change <= 0 {
+		t.Errorf("Expected a rating gain for Alice, got %+v", history)
+	}
+}
+
+func TestImport_JSON(t *testing.T) {
+	s, err := store.NewTestStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	archive, err := Read(strings.NewReader(`{"games": [
+		{"total_points": 301, "best_of": 1, "double_out": false, "players": ["Dave"],
+		 "throws": [
+			{"player": "Dave", "points": 20, "multiplier": 3},
+			{"player": "Dave", "points": 20, "multiplier": 3},
+			{"player": "Dave", "points": 20, "multiplier": 3},
+			{"player": "dave", "points": 20, "multiplier": 3},
+			{"player": " Dave ", "points": 20, "multiplier": 3},
+			{"player": "Dave", "points": 1}
+		 ]},
+		{"ref": "abandoned", "total_points": 501, "best_of": 3, "players": ["Eve"],
+		 "throws": [{"player": "Eve", "points": 20}]},
+		{"ref": "bad-format", "total_points": 401, "best_of": 1, "players": ["Eve"],
+		 "throws": [{"player": "Eve", "points": 20}]}
+	]}`), FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	report, err := Import(s, archive)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+
+	if report.GamesImported != 1 || report.GamesFailed != 2 {
+		t.Errorf("Expected 1 imported and 2 failed games, got %d and %d", report.GamesImported, report.GamesFailed)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Game != "abandoned" || report.Errors[1].Game != "bad-format" {
+		t.Fatalf("Expected errors for the abandoned and the bad game, got %+v", report.Errors)
+	}
+	if !strings.Contains(report.Errors[0].Message, "not finished") {
+		t.Errorf("Expected unfinished game error, got %q", report.Errors[0].Message)
+	}
+
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 1 || users[0].Name != "Dave" {
+		t.Errorf("Expected only Dave, got %+v", users)
+	}
+}
+
+func TestRead_InvalidArchive(t *testing.T) {
+	if _, err := Read(strings.NewReader(`game,points`), FormatCSV); err == nil {
+		t.Error("Expected error for missing player column")
+	}
+	if _, err := Read(strings.NewReader(`{"games": [`), FormatJSON); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+	if _, err := Read(strings.NewReader(``), Format("xml")); err != ErrUnknownFormat {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
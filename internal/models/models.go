@@ -6,6 +6,25 @@ type User struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
+	// ArchivedAt is set for players hidden from the player list
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+
+	// Nickname is shown instead of the name on the scoreboard
+	Nickname string `json:"nickname,omitempty"`
+	// Avatar is the file name of the profile picture, served by
+	// GET /api/users/{id}/avatar
+	Avatar string `json:"avatar,omitempty"`
+	// WalkOnColour is the player's colour as #rrggbb
+	WalkOnColour string          `json:"walk_on_colour,omitempty"`
+	Preferences  GamePreferences `json:"preferences"`
+}
+
+// GamePreferences are the settings a new game starts with for a player.
+// Zero values mean no preference.
+type GamePreferences struct {
+	TotalPoints int   `json:"total_points,omitempty"`
+	BestOf      int   `json:"best_of,omitempty"`
+	DoubleOut   *bool `json:"double_out,omitempty"`
 }
 
 type GameStatus string
@@ -45,6 +64,7 @@ type TurnStatus struct {
 	PlayerIndex       int `json:"player_index"` // Index in Players array
 	ThrowNumber       int `json:"throw_number"` // 0, 1, 2
 	CurrentTurnPoints int `json:"current_turn_points"`
+	VisitNumber       int `json:"visit_number"` // Visits completed in the current leg
 }
 
 type Throw struct {
@@ -55,5 +75,98 @@ type Throw struct {
 	Multiplier int       `json:"multiplier"` // 1, 2, 3
 	Valid      bool      `json:"valid"`      // False if bust
 	ScoreAfter int       `json:"score_after"`
+	SetNo      int       `json:"set_no"`
+	LegNo      int       `json:"leg_no"`
+	VisitNo    int       `json:"visit_no"` // Visit within the leg, across all players
+	DartNo     int       `json:"dart_no"`  // 1, 2, 3
 	CreatedAt  time.Time `json:"created_at"`
 }
+
+// Leg is a single leg played to zero. The engine currently decides each set
+// with one leg, so LegNo is always 1.
+type Leg struct {
+	ID         int        `json:"id"`
+	GameID     int        `json:"game_id"`
+	SetNo      int        `json:"set_no"`
+	LegNo      int        `json:"leg_no"`
+	WinnerID   *int       `json:"winner_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Visit is one player's turn of up to three darts within a leg.
+type Visit struct {
+	ID          int       `json:"id"`
+	LegID       int       `json:"leg_id"`
+	GameID      int       `json:"game_id"`
+	UserID      int       `json:"user_id"`
+	VisitNo     int       `json:"visit_no"`
+	ScoreBefore int       `json:"score_before"`
+	ScoreAfter  int       `json:"score_after"`
+	Darts       int       `json:"darts"`
+	Bust        bool      `json:"bust"`
+	Checkout    bool      `json:"checkout"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AchievementType string
+
+const (
+	AchievementFirst180        AchievementType = "first_180"
+	AchievementHighestCheckout AchievementType = "highest_checkout" // New personal best
+	AchievementBestLeg         AchievementType = "best_leg"         // New personal best in darts
+	AchievementNineDarter      AchievementType = "nine_darter"
+	AchievementTonPlusFinish   AchievementType = "ton_plus_finish"
+	AchievementWinStreak       AchievementType = "win_streak"
+)
+
+// Achievement is a milestone a player unlocked with a specific throw
+type Achievement struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"user_id"`
+	Type      AchievementType `json:"type"`
+	Value     int             `json:"value"` // Checkout, leg darts or streak length
+	Title     string          `json:"title"`
+	GameID    int             `json:"game_id"`
+	ThrowID   int             `json:"throw_id"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Role decides what an account or API token may change
+type Role string
+
+const (
+	// RoleScorer manages players and records games
+	RoleScorer Role = "scorer"
+	// RoleAdmin may also delete data and use the maintenance endpoints
+	RoleAdmin Role = "admin"
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return r == RoleScorer || r == RoleAdmin
+}
+
+// Allows reports whether r grants what required grants. Admins may do
+// everything scorers may.
+func (r Role) Allows(required Role) bool {
+	return r == required || r == RoleAdmin
+}
+
+// Account is a login of the web UI
+type Account struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIToken is a bearer token for kiosks and integrations. The token itself
+// is only shown when it is created.
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Role       Role       `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
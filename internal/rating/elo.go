@@ -0,0 +1,65 @@
+package rating
+
+import "math"
+
+const (
+	// InitialRating is the rating of a player without rated games
+	InitialRating = 1500.0
+	// BaseK is the maximum rating change of a best-of-1 game between two players
+	BaseK = 32.0
+)
+
+// Result is one player's outcome in a finished game
+type Result struct {
+	UserID int
+	Rating float64 // Rating before the game
+	Score  int     // Sets won; higher beats lower, equal is a draw
+}
+
+// FormatWeight scales K by the match format, so a best-of-5 counts twice as
+// much as a best-of-1
+func FormatWeight(bestOfSets int) float64 {
+	if bestOfSets < 1 {
+		bestOfSets = 1
+	}
+	return 1 + float64(bestOfSets-1)*0.25
+}
+
+// Expected returns the expected score of a player rated r against one rated
+// opponent
+func Expected(r, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-r)/400))
+}
+
+// Update returns the new rating of every player. Multi-player games are
+// treated as pairwise matches between all players, and the change is divided
+// by the number of opponents so a four player game moves ratings as much as
+// a single match.
+func Update(results []Result, bestOfSets int) map[int]float64 {
+	updated := make(map[int]float64, len(results))
+	for _, r := range results {
+		updated[r.UserID] = r.Rating
+	}
+	if len(results) < 2 {
+		return updated
+	}
+
+	k := BaseK * FormatWeight(bestOfSets) / float64(len(results)-1)
+	for _, r := range results {
+		delta := 0.0
+		for _, o := range results {
+			if o.UserID == r.UserID {
+				continue
+			}
+			actual := 0.5
+			if r.Score > o.Score {
+				actual = 1
+			} else if r.Score < o.Score {
+				actual = 0
+			}
+			delta += actual - Expected(r.Rating, o.Rating)
+		}
+		updated[r.UserID] = r.Rating + k*delta
+	}
+	return updated
+}
@@ -0,0 +1,73 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdate_TwoPlayers(t *testing.T) {
+	tests := []struct {
+		name       string
+		bestOfSets int
+		wantChange float64
+	}{
+		{"Best of 1", 1, 16},
+		{"Best of 3", 3, 24},
+		{"Best of 5", 5, 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Update([]Result{
+				{UserID: 1, Rating: InitialRating, Score: 1},
+				{UserID: 2, Rating: InitialRating, Score: 0},
+			}, tt.bestOfSets)
+
+			if math.Abs(got[1]-(InitialRating+tt.wantChange)) > 1e-9 {
+				t.Errorf("Expected winner rating %.1f, got %.4f", InitialRating+tt.wantChange, got[1])
+			}
+			if math.Abs(got[2]-(InitialRating-tt.wantChange)) > 1e-9 {
+				t.Errorf("Expected loser rating %.1f, got %.4f", InitialRating-tt.wantChange, got[2])
+			}
+		})
+	}
+}
+
+func TestUpdate_Upset(t *testing.T) {
+	got := Update([]Result{
+		{UserID: 1, Rating: 1400, Score: 1},
+		{UserID: 2, Rating: 1600, Score: 0},
+	}, 1)
+
+	// The underdog gains more than half of K
+	if got[1]-1400 <= BaseK/2 {
+		t.Errorf("Expected underdog to gain more than %.0f, got %.2f", BaseK/2, got[1]-1400)
+	}
+}
+
+func TestUpdate_MultiPlayer(t *testing.T) {
+	got := Update([]Result{
+		{UserID: 1, Rating: 1500, Score: 2},
+		{UserID: 2, Rating: 1550, Score: 1},
+		{UserID: 3, Rating: 1450, Score: 1},
+	}, 3)
+
+	total := got[1] + got[2] + got[3]
+	if math.Abs(total-4500) > 1e-9 {
+		t.Errorf("Expected ratings to stay zero-sum, total %.4f", total)
+	}
+	if got[1] <= 1500 {
+		t.Errorf("Expected winner to gain, got %.2f", got[1])
+	}
+	// Players 2 and 3 drew with each other, so the higher rated one loses more
+	if 1550-got[2] <= 1450-got[3] {
+		t.Errorf("Expected higher rated loser to drop more, got %.2f and %.2f", got[2], got[3])
+	}
+}
+
+func TestUpdate_SinglePlayer(t *testing.T) {
+	got := Update([]Result{{UserID: 1, Rating: 1500, Score: 1}}, 1)
+	if got[1] != 1500 {
+		t.Errorf("Expected solo games to leave the rating unchanged, got %.2f", got[1])
+	}
+}
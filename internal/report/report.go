@@ -0,0 +1,194 @@
+// Package report renders printable match reports. Only the PDF core fonts
+// are used, so reports are rendered without any files or network access.
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+// lineHeight is the height of a table row in mm
+const lineHeight = 6.0
+
+// WriteGamePDF renders the match report of a game: players, format, result,
+// per-set scores, the game statistics and a visit-by-visit scoresheet
+func WriteGamePDF(w io.Writer, timeline *store.Timeline, stats *store.GameStatistics) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(fmt.Sprintf("Match Report - Game #%d", timeline.GameID), true)
+	pdf.SetCreationDate(timeline.CreatedAt)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Core fonts use cp1252, player names are UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	names := make(map[int]string)
+	for _, p := range stats.Players {
+		names[p.UserID] = tr(p.UserName)
+	}
+	name := func(userID int) string {
+		if n, ok := names[userID]; ok {
+			return n
+		}
+		return "Unknown"
+	}
+
+	// Title, format and result
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Match Report", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, lineHeight, fmt.Sprintf("Game #%d - %s", timeline.GameID, timeline.CreatedAt.Format("2006-01-02 15:04")), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, lineHeight, "Format: "+formatSettings(timeline.Settings), "", 1, "L", false, 0, "")
+	result := "Result: in progress"
+	if timeline.WinnerID != nil {
+		result = fmt.Sprintf("Result: %s wins %s", name(*timeline.WinnerID), setScore(timeline.Players))
+	}
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(0, lineHeight, result, "", 1, "L", false, 0, "")
+
+	// Players and overall statistics
+	heading(pdf, "Players")
+	playerCols := []float64{70, 25, 35, 30, 30}
+	tableHeader(pdf, playerCols, "Player", "Sets", "3-Dart Avg", "Throws", "Points")
+	sets := make(map[int]int)
+	for _, p := range timeline.Players {
+		sets[p.UserID] = p.SetsWon
+	}
+	for _, p := range stats.Players {
+		tableRow(pdf, playerCols,
+			name(p.UserID),
+			strconv.Itoa(sets[p.UserID]),
+			fmt.Sprintf("%.2f", p.OverallStats.Average3Dart),
+			strconv.Itoa(p.OverallStats.TotalThrows),
+			strconv.Itoa(p.OverallStats.TotalPoints))
+	}
+
+	// Per-set scores; every player's set statistics share the set numbers
+	if len(stats.Players) > 0 && len(stats.Players[0].SetStats) > 0 {
+		heading(pdf, "Sets")
+		setCols := []float64{20, 50}
+		header := []string{"Set", "Winner"}
+		for _, p := range stats.Players {
+			setCols = append(setCols, 120/float64(len(stats.Players)))
+			header = append(header, name(p.UserID)+" Avg (Darts)")
+		}
+		tableHeader(pdf, setCols, header...)
+		for i, set := range stats.Players[0].SetStats {
+			row := []string{strconv.Itoa(set.SetNumber), "-"}
+			for _, p := range stats.Players {
+				s := p.SetStats[i]
+				if s.WonSet {
+					row[1] = name(p.UserID)
+				}
+				row = append(row, fmt.Sprintf("%.2f (%d)", s.Average3Dart, s.TotalThrows))
+			}
+			tableRow(pdf, setCols, row...)
+		}
+	}
+
+	// Scoresheet
+	heading(pdf, "Scoresheet")
+	sheetCols := []float64{12, 50, 60, 22, 22, 24}
+	for _, set := range timeline.Sets {
+		for _, leg := range set.Legs {
+			pdf.SetFont("Helvetica", "B", 10)
+			pdf.CellFormat(0, lineHeight, fmt.Sprintf("Set %d, Leg %d", set.SetNo, leg.LegNo), "", 1, "L", false, 0, "")
+			tableHeader(pdf, sheetCols, "#", "Player", "Darts", "Scored", "Left", "")
+			for _, v := range leg.Visits {
+				darts := make([]string, len(v.Darts))
+				for i, d := range v.Darts {
+					darts[i] = FormatDart(d.Points, d.Multiplier)
+				}
+				note := ""
+				if v.Bust {
+					note = "BUST"
+				} else if v.Checkout {
+					note = "CHECKOUT"
+				}
+				tableRow(pdf, sheetCols,
+					strconv.Itoa(v.VisitNo),
+					name(v.UserID),
+					strings.Join(darts, "  "),
+					strconv.Itoa(v.Scored),
+					strconv.Itoa(v.ScoreAfter),
+					note)
+			}
+			pdf.Ln(2)
+		}
+	}
+	if len(timeline.Sets) == 0 {
+		pdf.SetFont("Helvetica", "I", 10)
+		pdf.CellFormat(0, lineHeight, "No darts thrown yet.", "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// FormatDart returns the scoreboard notation of a dart, e.g. T20 or D16
+func FormatDart(points, multiplier int) string {
+	switch {
+	case points == 0:
+		return "Miss"
+	case points == 25 && multiplier == 2:
+		return "Bullseye"
+	case points == 25:
+		return "Bull"
+	case multiplier == 3:
+		return "T" + strconv.Itoa(points)
+	case multiplier == 2:
+		return "D" + strconv.Itoa(points)
+	}
+	return strconv.Itoa(points)
+}
+
+func formatSettings(s models.GameSettings) string {
+	out := "Straight out"
+	if s.DoubleOut {
+		out = "Double out"
+	}
+	return fmt.Sprintf("%d, best of %d sets, %s", s.TotalPoints, s.BestOfSets, out)
+}
+
+// setScore returns the sets won by each player, e.g. 2-1
+func setScore(players []models.GamePlayer) string {
+	scores := make([]string, len(players))
+	for i, p := range players {
+		scores[i] = strconv.Itoa(p.SetsWon)
+	}
+	return strings.Join(scores, "-")
+}
+
+func heading(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, title, "B", 1, "L", false, 0, "")
+	pdf.Ln(2)
+}
+
+func tableHeader(pdf *fpdf.Fpdf, widths []float64, cols ...string) {
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(226, 232, 240)
+	for i, col := range cols {
+		pdf.CellFormat(widths[i], lineHeight, col, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func tableRow(pdf *fpdf.Fpdf, widths []float64, cols ...string) {
+	pdf.SetFont("Helvetica", "", 9)
+	for i, col := range cols {
+		pdf.CellFormat(widths[i], lineHeight, col, "1", 0, "L", false, 0, "")
+	}
+	pdf.Ln(-1)
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/store"
+)
+
+func TestFormatDart(t *testing.T) {
+	tests := []struct {
+		points, multiplier int
+		want               string
+	}{
+		{0, 1, "Miss"},
+		{20, 1, "20"},
+		{16, 2, "D16"},
+		{20, 3, "T20"},
+		{25, 1, "Bull"},
+		{25, 2, "Bullseye"},
+	}
+	for _, tt := range tests {
+		if got := FormatDart(tt.points, tt.multiplier); got != tt.want {
+			t.Errorf("FormatDart(%d, %d) = %q, want %q", tt.points, tt.multiplier, got, tt.want)
+		}
+	}
+}
+
+func TestWriteGamePDF(t *testing.T) {
+	winner := 1
+	timeline := &store.Timeline{
+		GameID:    7,
+		Status:    models.GameStatusFinished,
+		Settings:  models.GameSettings{TotalPoints: 101, BestOfSets: 1, DoubleOut: true},
+		WinnerID:  &winner,
+		CreatedAt: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC),
+		Players: []models.GamePlayer{
+			{UserID: 1, SetsWon: 1},
+			{UserID: 2, CurrentPoints: 41},
+		},
+		Sets: []store.TimelineSet{{
+			SetNo:    1,
+			WinnerID: &winner,
+			Legs: []store.TimelineLeg{{
+				LegNo:    1,
+				WinnerID: &winner,
+				Visits: []store.TimelineVisit{
+					{VisitNo: 1, UserID: 1, ScoreBefore: 101, ScoreAfter: 40, Scored: 61, Darts: []store.TimelineDart{
+						{Points: 20, Multiplier: 3}, {Points: 1, Multiplier: 1}, {Points: 0, Multiplier: 1},
+					}},
+					{VisitNo: 2, UserID: 2, ScoreBefore: 101, ScoreAfter: 41, Scored: 60, Darts: []store.TimelineDart{
+						{Points: 20, Multiplier: 1}, {Points: 20, Multiplier: 1}, {Points: 20, Multiplier: 1},
+					}},
+					{VisitNo: 3, UserID: 1, ScoreBefore: 40, ScoreAfter: 0, Scored: 40, Checkout: true, Darts: []store.TimelineDart{
+						{Points: 20, Multiplier: 2},
+					}},
+				},
+			}},
+		}},
+	}
+	stats := &store.GameStatistics{
+		GameID:          7,
+		TotalSetsPlayed: 1,
+		Players: []store.PlayerGameStats{
+			{UserID: 1, UserName: "Jürgen", OverallStats: store.OverallStats{TotalThrows: 4, TotalPoints: 101, Average3Dart: 75.75},
+				SetStats: []store.SetStats{{SetNumber: 1, TotalThrows: 4, TotalPoints: 101, Average3Dart: 75.75, WonSet: true}}},
+			{UserID: 2, UserName: "Bob", OverallStats: store.OverallStats{TotalThrows: 3, TotalPoints: 60, Average3Dart: 60},
+				SetStats: []store.SetStats{{SetNumber: 1, TotalThrows: 3, TotalPoints: 60, Average3Dart: 60}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGamePDF(&buf, timeline, stats); err != nil {
+		t.Fatalf("WriteGamePDF() error = %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("Expected PDF header, got %q", buf.Bytes()[:min(buf.Len(), 8)])
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("%%EOF")) {
+		t.Error("Expected PDF trailer")
+	}
+}
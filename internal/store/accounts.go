@@ -0,0 +1,263 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+var (
+	ErrDuplicateAccount = errors.New("account already exists")
+	ErrLastAdmin        = errors.New("cannot remove the last admin account")
+	ErrInvalidRole      = errors.New("role must be admin or scorer")
+)
+
+// tokenUseInterval limits how often the last use of an API token is written
+const tokenUseInterval = time.Minute
+
+// normaliseUsername makes usernames case-insensitive
+func normaliseUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// CreateAccount adds a login with the bcrypt hash of its password
+func (s *SQLStore) CreateAccount(username, passwordHash string, role models.Role) (*models.Account, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	username = normaliseUsername(username)
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM accounts WHERE username = ?)`, username).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrDuplicateAccount
+	}
+
+	var a models.Account
+	err := s.db.QueryRow(`
+		INSERT INTO accounts (username, password_hash, role)
+		VALUES (?, ?, ?)
+		RETURNING id, username, role, created_at`,
+		username, passwordHash, role).Scan(&a.ID, &a.Username, &a.Role, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAccountByUsername returns an account with its password hash, or nil if
+// there is none
+func (s *SQLStore) GetAccountByUsername(username string) (*models.Account, string, error) {
+	var a models.Account
+	var hash string
+	err := s.read.QueryRow(`
+		SELECT id, username, role, created_at, password_hash
+		FROM accounts WHERE username = ?`,
+		normaliseUsername(username)).Scan(&a.ID, &a.Username, &a.Role, &a.CreatedAt, &hash)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &a, hash, nil
+}
+
+// ListAccounts returns the accounts by username
+func (s *SQLStore) ListAccounts() ([]models.Account, error) {
+	rows, err := s.read.Query(`SELECT id, username, role, created_at FROM accounts ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		var a models.Account
+		if err := rows.Scan(&a.ID, &a.Username, &a.Role, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// SetAccountPassword replaces the password of an account and ends its
+// sessions
+func (s *SQLStore) SetAccountPassword(id int, passwordHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE accounts SET password_hash = ? WHERE id = ?`, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE account_id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteAccount removes an account and its sessions. The last admin account
+// cannot be removed.
+func (s *SQLStore) DeleteAccount(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role models.Role
+	if err := tx.QueryRow(`SELECT role FROM accounts WHERE id = ?`, id).Scan(&role); err != nil {
+		return err
+	}
+	if role == models.RoleAdmin {
+		var admins int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE role = ?`, models.RoleAdmin).Scan(&admins); err != nil {
+			return err
+		}
+		if admins == 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE account_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM accounts WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateSession stores a login session by the hash of its token. Expired
+// sessions are removed on the way.
+func (s *SQLStore) CreateSession(tokenHash string, accountID int, expiresAt time.Time) error {
+	now := time.Now().UTC().Format(sqliteTimeFormat)
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`INSERT INTO sessions (token_hash, account_id, expires_at) VALUES (?, ?, ?)`,
+		tokenHash, accountID, expiresAt.UTC().Format(sqliteTimeFormat))
+	return err
+}
+
+// GetSession returns the account of an unexpired session, or nil if there is
+// none
+func (s *SQLStore) GetSession(tokenHash string) (*models.Account, error) {
+	var a models.Account
+	err := s.read.QueryRow(`
+		SELECT a.id, a.username, a.role, a.created_at
+		FROM sessions s
+		JOIN accounts a ON a.id = s.account_id
+		WHERE s.token_hash = ? AND s.expires_at > ?`,
+		tokenHash, time.Now().UTC().Format(sqliteTimeFormat)).Scan(&a.ID, &a.Username, &a.Role, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteSession ends a session
+func (s *SQLStore) DeleteSession(tokenHash string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// CreateAPIToken stores an API token by its hash
+func (s *SQLStore) CreateAPIToken(name string, role models.Role, tokenHash string) (*models.APIToken, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	var t models.APIToken
+	err := s.db.QueryRow(`
+		INSERT INTO api_tokens (name, role, token_hash)
+		VALUES (?, ?, ?)
+		RETURNING id, name, role, created_at, last_used_at`,
+		strings.TrimSpace(name), role, tokenHash).Scan(&t.ID, &t.Name, &t.Role, &t.CreatedAt, &t.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UseAPIToken returns the API token with the hash, or nil if there is none,
+// and records that it was used
+func (s *SQLStore) UseAPIToken(tokenHash string) (*models.APIToken, error) {
+	var t models.APIToken
+	err := s.read.QueryRow(`
+		SELECT id, name, role, created_at, last_used_at
+		FROM api_tokens WHERE token_hash = ?`,
+		tokenHash).Scan(&t.ID, &t.Name, &t.Role, &t.CreatedAt, &t.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A kiosk sends its token with every dart, so the time is only written
+	// now and then
+	now := time.Now().UTC()
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= tokenUseInterval {
+		_, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now.Format(sqliteTimeFormat), t.ID)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsedAt = &now
+	}
+	return &t, nil
+}
+
+// ListAPITokens returns the API tokens, oldest first
+func (s *SQLStore) ListAPITokens() ([]models.APIToken, error) {
+	rows, err := s.read.Query(`SELECT id, name, role, created_at, last_used_at FROM api_tokens ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var t models.APIToken
+		if err := rows.Scan(&t.ID, &t.Name, &t.Role, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes an API token
+func (s *SQLStore) DeleteAPIToken(id int) error {
+	result, err := s.db.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
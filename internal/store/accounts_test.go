@@ -0,0 +1,113 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+func TestAccountsAndSessions(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	admin, err := s.CreateAccount(" Admin ", "hash", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if admin.Username != "admin" || admin.Role != models.RoleAdmin {
+		t.Errorf("Expected a lower case admin, got %+v", admin)
+	}
+	if _, err := s.CreateAccount("ADMIN", "hash", models.RoleScorer); err != ErrDuplicateAccount {
+		t.Errorf("Expected ErrDuplicateAccount, got %v", err)
+	}
+	if _, err := s.CreateAccount("root", "hash", "owner"); err != ErrInvalidRole {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+
+	a, hash, err := s.GetAccountByUsername("Admin")
+	if err != nil || a == nil || a.ID != admin.ID || hash != "hash" {
+		t.Fatalf("Expected the admin with its hash, got %+v, %q, %v", a, hash, err)
+	}
+	if a, _, err := s.GetAccountByUsername("nobody"); a != nil || err != nil {
+		t.Errorf("Expected no account, got %+v, %v", a, err)
+	}
+
+	if err := s.CreateSession("current", admin.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if err := s.CreateSession("expired", admin.ID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if a, err := s.GetSession("current"); err != nil || a == nil || a.ID != admin.ID {
+		t.Errorf("Expected the admin's session, got %+v, %v", a, err)
+	}
+	if a, err := s.GetSession("expired"); err != nil || a != nil {
+		t.Errorf("Expected no expired session, got %+v, %v", a, err)
+	}
+
+	// A new password ends the sessions
+	if err := s.SetAccountPassword(admin.ID, "new hash"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	if a, _ := s.GetSession("current"); a != nil {
+		t.Error("Expected the session to end with the password change")
+	}
+
+	if err := s.DeleteAccount(admin.ID); err != ErrLastAdmin {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+	scorer, err := s.CreateAccount("scorer", "hash", models.RoleScorer)
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if err := s.DeleteAccount(scorer.ID); err != nil {
+		t.Errorf("Failed to delete account: %v", err)
+	}
+	if err := s.DeleteAccount(scorer.ID); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	created, err := s.CreateAPIToken("Kiosk", models.RoleScorer, "token hash")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	if created.LastUsedAt != nil {
+		t.Errorf("Expected an unused token, got %+v", created)
+	}
+
+	used, err := s.UseAPIToken("token hash")
+	if err != nil || used == nil || used.ID != created.ID || used.LastUsedAt == nil {
+		t.Fatalf("Expected the used token, got %+v, %v", used, err)
+	}
+	if unknown, err := s.UseAPIToken("other"); err != nil || unknown != nil {
+		t.Errorf("Expected no token, got %+v, %v", unknown, err)
+	}
+
+	tokens, err := s.ListAPITokens()
+	if err != nil {
+		t.Fatalf("Failed to list tokens: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("Expected the used token, got %+v", tokens)
+	}
+
+	if err := s.DeleteAPIToken(created.ID); err != nil {
+		t.Fatalf("Failed to delete token: %v", err)
+	}
+	if used, _ := s.UseAPIToken("token hash"); used != nil {
+		t.Error("Expected the revoked token to be gone")
+	}
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// winStreakMilestones are the consecutive wins that unlock an achievement
+var winStreakMilestones = []int{3, 5, 10}
+
+// achievementTitle returns the text shown for an unlocked achievement
+func achievementTitle(t models.AchievementType, value int) string {
+	switch t {
+	case models.AchievementFirst180:
+		return "First 180"
+	case models.AchievementHighestCheckout:
+		return fmt.Sprintf("New highest checkout: %d", value)
+	case models.AchievementBestLeg:
+		return fmt.Sprintf("New best leg: %d darts", value)
+	case models.AchievementNineDarter:
+		return "Nine-darter"
+	case models.AchievementTonPlusFinish:
+		return fmt.Sprintf("First ton-plus finish: %d", value)
+	case models.AchievementWinStreak:
+		return fmt.Sprintf("%d wins in a row", value)
+	}
+	return string(t)
+}
+
+// EvaluateAchievements checks a saved throw for newly unlocked achievements,
+// persists them and returns them. The game must reflect the state after the
+// throw. RecordThrow already does this for the throws it saves.
+func (s *SQLStore) EvaluateAchievements(t *models.Throw, g *models.Game) ([]models.Achievement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	unlocked, err := evaluateAchievements(tx, t, g)
+	if err != nil {
+		return nil, err
+	}
+
+	return unlocked, tx.Commit()
+}
+
+func evaluateAchievements(tx *txn, t *models.Throw, g *models.Game) ([]models.Achievement, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+
+	var unlocked []models.Achievement
+	unlock := func(achievementType models.AchievementType, value int) error {
+		a := models.Achievement{
+			UserID:  t.UserID,
+			Type:    achievementType,
+			Value:   value,
+			Title:   achievementTitle(achievementType, value),
+			GameID:  t.GameID,
+			ThrowID: t.ID,
+		}
+		// The unique indexes of one-off achievements turn an unlock that
+		// another throw got to first into no row
+		err := tx.QueryRow(`
+			INSERT INTO achievements (user_id, type, value, game_id, throw_id)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+			RETURNING id, created_at`,
+			a.UserID, a.Type, a.Value, a.GameID, a.ThrowID).Scan(&a.ID, &a.CreatedAt)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		unlocked = append(unlocked, a)
+		return nil
+	}
+
+	var visitID, scoreBefore, scoreAfter, darts int
+	var legID int
+	err := tx.QueryRow(`
+		SELECT v.id, v.leg_id, v.score_before, v.score_after, v.darts
+		FROM visits v
+		JOIN legs l ON v.leg_id = l.id
+		WHERE l.game_id = ? AND l.set_no = ? AND l.leg_no = ? AND v.visit_no = ?`,
+		t.GameID, t.SetNo, t.LegNo, t.VisitNo).Scan(&visitID, &legID, &scoreBefore, &scoreAfter, &darts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 180
+	if scoreBefore-scoreAfter == 180 {
+		has, err := hasAchievement(tx, t.UserID, models.AchievementFirst180)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			if err := unlock(models.AchievementFirst180, 180); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if t.ScoreAfter == 0 {
+		checkout := scoreBefore
+
+		// Personal best checkout, compared against all earlier checkouts
+		var previousBest int
+		err := tx.QueryRow(`
+			SELECT COALESCE(MAX(score_before), 0) FROM visits
+			WHERE user_id = ? AND checkout = 1 AND id != ?`, t.UserID, visitID).Scan(&previousBest)
+		if err != nil {
+			return nil, err
+		}
+		if checkout > previousBest {
+			if err := unlock(models.AchievementHighestCheckout, checkout); err != nil {
+				return nil, err
+			}
+		}
+
+		if checkout >= 100 {
+			has, err := hasAchievement(tx, t.UserID, models.AchievementTonPlusFinish)
+			if err != nil {
+				return nil, err
+			}
+			if !has {
+				if err := unlock(models.AchievementTonPlusFinish, checkout); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Darts for this leg against the fewest of any earlier won leg
+		var legDarts int
+		if err := tx.QueryRow(`SELECT SUM(darts) FROM visits WHERE leg_id = ? AND user_id = ?`, legID, t.UserID).Scan(&legDarts); err != nil {
+			return nil, err
+		}
+		var previousBestLeg sql.NullInt64
+		err = tx.QueryRow(`
+			SELECT MIN(darts) FROM (
+				SELECT SUM(v.darts) AS darts
+				FROM legs l
+				JOIN visits v ON v.leg_id = l.id AND v.user_id = l.winner_id
+				WHERE l.winner_id = ? AND l.id != ?
+				GROUP BY l.id
+			) won_legs`, t.UserID, legID).Scan(&previousBestLeg)
+		if err != nil {
+			return nil, err
+		}
+		if !previousBestLeg.Valid || legDarts < int(previousBestLeg.Int64) {
+			if err := unlock(models.AchievementBestLeg, legDarts); err != nil {
+				return nil, err
+			}
+		}
+
+		// 9 darts is the minimum for 501
+		if g.Settings.TotalPoints == 501 && legDarts <= 9 {
+			if err := unlock(models.AchievementNineDarter, legDarts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if g.Status == models.GameStatusFinished && g.WinnerID != nil && *g.WinnerID == t.UserID {
+		streak, err := currentWinStreak(tx, t.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for _, milestone := range winStreakMilestones {
+			if streak != milestone {
+				continue
+			}
+			var has bool
+			err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM achievements WHERE user_id = ? AND type = ? AND value = ?)`,
+				t.UserID, models.AchievementWinStreak, milestone).Scan(&has)
+			if err != nil {
+				return nil, err
+			}
+			if !has {
+				if err := unlock(models.AchievementWinStreak, milestone); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return unlocked, nil
+}
+
+func hasAchievement(tx *txn, userID int, achievementType models.AchievementType) (bool, error) {
+	var has bool
+	err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM achievements WHERE user_id = ? AND type = ?)`,
+		userID, achievementType).Scan(&has)
+	return has, err
+}
+
+// currentWinStreak counts the player's consecutive wins, newest game first
+func currentWinStreak(tx *txn, userID int) (int, error) {
+	rows, err := tx.Query(`
+		SELECT g.winner_id
+		FROM games g
+		JOIN game_players gp ON gp.game_id = g.id AND gp.user_id = ?
+		WHERE g.status = ?
+		ORDER BY g.id DESC`, userID, models.GameStatusFinished)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	streak := 0
+	for rows.Next() {
+		var winnerID sql.NullInt64
+		if err := rows.Scan(&winnerID); err != nil {
+			return 0, err
+		}
+		if !winnerID.Valid || int(winnerID.Int64) != userID {
+			break
+		}
+		streak++
+	}
+	return streak, rows.Err()
+}
+
+// GetAchievements returns a player's unlocked achievements, newest first
+func (s *SQLStore) GetAchievements(userID int) ([]models.Achievement, error) {
+	rows, err := s.read.Query(`
+		SELECT id, user_id, type, value, game_id, throw_id, created_at
+		FROM achievements
+		WHERE user_id = ?
+		ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	achievements := []models.Achievement{}
+	for rows.Next() {
+		var a models.Achievement
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Type, &a.Value, &a.GameID, &a.ThrowID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Title = achievementTitle(a.Type, a.Value)
+		achievements = append(achievements, a)
+	}
+	return achievements, rows.Err()
+}
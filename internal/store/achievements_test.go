@@ -0,0 +1,149 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/game"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// playDartsWithAchievements works like playDarts and collects the
+// achievements each dart unlocks
+func playDartsWithAchievements(t *testing.T, s *SQLStore, gameID int, darts []testDart) []models.Achievement {
+	t.Helper()
+	engine := game.NewEngine()
+	var unlocked []models.Achievement
+	for i, d := range darts {
+		g, err := s.GetGame(gameID)
+		if err != nil {
+			t.Fatalf("Failed to load game: %v", err)
+		}
+		throw, err := engine.ProcessThrow(g, d.userID, d.points, d.multiplier)
+		if err != nil {
+			t.Fatalf("Dart %d: ProcessThrow() error = %v", i, err)
+		}
+		achievements, err := s.RecordThrow(throw, g)
+		if err != nil {
+			t.Fatalf("Dart %d: Failed to record throw: %v", i, err)
+		}
+		unlocked = append(unlocked, achievements...)
+	}
+	return unlocked
+}
+
+func TestEvaluateAchievements(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, _ := s.CreateUser("Alice")
+	darts := []testDart{
+		{alice.ID, 20, 3}, {alice.ID, 20, 3}, {alice.ID, 20, 3}, // 180, 301 -> 121
+		{alice.ID, 20, 3}, {alice.ID, 19, 3}, {alice.ID, 2, 2}, // 121 checkout
+	}
+
+	g, _ := s.CreateGame(301, 1, true, []int{alice.ID})
+	unlocked := playDartsWithAchievements(t, s, g.ID, darts)
+
+	want := []models.AchievementType{
+		models.AchievementFirst180,
+		models.AchievementHighestCheckout,
+		models.AchievementTonPlusFinish,
+		models.AchievementBestLeg,
+	}
+	if len(unlocked) != len(want) {
+		t.Fatalf("Expected %d achievements, got %+v", len(want), unlocked)
+	}
+	for i, a := range unlocked {
+		if a.Type != want[i] {
+			t.Errorf("Achievement %d: expected %s, got %s", i, want[i], a.Type)
+		}
+	}
+	if unlocked[1].Value != 121 || unlocked[3].Value != 6 {
+		t.Errorf("Expected checkout 121 and a 6 dart leg, got %+v", unlocked)
+	}
+
+	// The same game again only continues the win streak
+	for i := 2; i <= 3; i++ {
+		g, _ := s.CreateGame(301, 1, true, []int{alice.ID})
+		unlocked = playDartsWithAchievements(t, s, g.ID, darts)
+	}
+	if len(unlocked) != 1 || unlocked[0].Type != models.AchievementWinStreak || unlocked[0].Value != 3 {
+		t.Errorf("Expected only a 3 game win streak, got %+v", unlocked)
+	}
+
+	stored, err := s.GetAchievements(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get achievements: %v", err)
+	}
+	if len(stored) != 5 || stored[0].Type != models.AchievementWinStreak || stored[0].Title != "3 wins in a row" {
+		t.Errorf("Unexpected stored achievements: %+v", stored)
+	}
+}
+
+func TestAchievements_UnlockedOnce(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, _ := s.CreateUser("Alice")
+	g, _ := s.CreateGame(301, 1, true, []int{alice.ID})
+	playDartsWithAchievements(t, s, g.ID, []testDart{{alice.ID, 20, 3}, {alice.ID, 20, 3}, {alice.ID, 20, 3}})
+
+	// What a concurrent throw runs into after both checked for a first 180
+	_, err = s.db.Exec(`INSERT INTO achievements (user_id, type, value, game_id, throw_id) SELECT ?, ?, 180, ?, MAX(id) FROM throws`,
+		alice.ID, models.AchievementFirst180, g.ID)
+	if !isUniqueViolation(err) {
+		t.Errorf("Expected a unique violation for a second first 180, got %v", err)
+	}
+
+	throw := &models.Throw{GameID: g.ID, UserID: alice.ID, Valid: true, SetNo: 1, LegNo: 1, VisitNo: 1, ScoreAfter: 121}
+	if unlocked, err := s.EvaluateAchievements(throw, g); err != nil || len(unlocked) != 0 {
+		t.Errorf("Expected nothing unlocked twice, got %+v, %v", unlocked, err)
+	}
+}
+
+func TestMigrateUp_RemovesDuplicateAchievements(t *testing.T) {
+	skipOnPostgres(t)
+	s, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, _ := s.CreateUser("Alice")
+	g, _ := s.CreateGame(301, 1, true, []int{alice.ID})
+	playDarts(t, s, g.ID, []testDart{{alice.ID, 20, 3}})
+	if _, err := s.MigrateDown(10); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	for _, a := range []struct {
+		achievementType models.AchievementType
+		value           int
+	}{
+		{models.AchievementFirst180, 180}, {models.AchievementFirst180, 180},
+		{models.AchievementWinStreak, 3}, {models.AchievementWinStreak, 3}, {models.AchievementWinStreak, 5},
+		{models.AchievementHighestCheckout, 40}, {models.AchievementHighestCheckout, 40},
+	} {
+		_, err := s.db.Exec(`INSERT INTO achievements (user_id, type, value, game_id, throw_id) SELECT ?, ?, ?, ?, MAX(id) FROM throws`,
+			alice.ID, a.achievementType, a.value, g.ID)
+		if err != nil {
+			t.Fatalf("Failed to insert achievement: %v", err)
+		}
+	}
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+
+	stored, err := s.GetAchievements(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get achievements: %v", err)
+	}
+	if len(stored) != 5 {
+		t.Errorf("Expected one first 180, two win streaks and both checkouts, got %+v", stored)
+	}
+}
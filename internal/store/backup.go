@@ -0,0 +1,113 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	ErrInvalidBackup = errors.New("invalid backup")
+	ErrBackupTooNew  = errors.New("backup schema is newer than this server")
+
+	// ErrBackupUnsupported is returned by Backup for PostgreSQL, which is
+	// backed up with its own tools such as pg_dump
+	ErrBackupUnsupported = errors.New("backups are not supported for this database")
+)
+
+// Backup writes a consistent snapshot of the database to path with VACUUM
+// INTO. It is safe to call while the server handles requests. The file must
+// not exist yet.
+func (s *SQLStore) Backup(path string) error {
+	if s.db.dialect != dialectSQLite {
+		return ErrBackupUnsupported
+	}
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// VerifyBackup checks that path is an intact database with a schema this
+// server can run
+func VerifyBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	// Read-only, so a wrong path never creates an empty database
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%w: integrity check: %s", ErrInvalidBackup, result)
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if latest := latestVersion(); version > latest {
+		return fmt.Errorf("%w: version %d, supported %d", ErrBackupTooNew, version, latest)
+	}
+	return nil
+}
+
+// RestoreBackup replaces the database at dbPath with a verified copy of the
+// backup. The replaced database is kept as dbPath.pre-restore. The server
+// must not be running while restoring.
+func RestoreBackup(backupPath, dbPath string) error {
+	if err := VerifyBackup(backupPath); err != nil {
+		return err
+	}
+
+	src, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Copy next to the database first, so the swap is a rename
+	tmpPath := dbPath + ".restore"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	// Journal files of the old database must not be applied to the backup.
+	// The WAL may hold its last transactions, so it moves along with it.
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		err := os.Rename(dbPath+suffix, dbPath+".pre-restore"+suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, dbPath)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "darts.db")
+	backupPath := filepath.Join(dir, "backup.db")
+
+	s, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := s.CreateUser("Alice"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := s.Backup(backupPath); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	if _, err := s.CreateUser("Bob"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	s.Close()
+
+	if err := VerifyBackup(backupPath); err != nil {
+		t.Fatalf("Expected valid backup, got %v", err)
+	}
+	if err := RestoreBackup(backupPath, dbPath); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); err != nil {
+		t.Errorf("Expected the replaced database to be kept: %v", err)
+	}
+
+	s, err = NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open restored store: %v", err)
+	}
+	defer s.Close()
+
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 1 || users[0].Name != "Alice" {
+		t.Errorf("Expected only Alice after restore, got %+v", users)
+	}
+}
+
+func TestVerifyBackup_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "invalid.db")
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := VerifyBackup(path); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup, got %v", err)
+	}
+
+	missing := filepath.Join(dir, "missing.db")
+	if err := VerifyBackup(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected not exist error, got %v", err)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected verification not to create the file")
+	}
+}
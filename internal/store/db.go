@@ -1,164 +1,68 @@
 package store
 
 import (
-	"database/sql"
-	"fmt"
-	"log"
+	"errors"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
-type Store struct {
-	db *sql.DB
+// SQLStore is the Store backed by SQLite or PostgreSQL
+type SQLStore struct {
+	db *conn
+	// read serves queries that do not write. On SQLite it is a separate
+	// read-only pool, on PostgreSQL the same as db.
+	read *conn
 }
 
-type migration struct {
-	version int
-	up      func(*sql.Tx) error
+// NewStore opens the SQLite database at dbPath with the default options and
+// migrates it to the current schema
+func NewStore(dbPath string) (*SQLStore, error) {
+	return NewStoreWithOptions(dbPath, DefaultSQLiteOptions())
 }
 
-var migrations = []migration{
-	{
-		version: 1,
-		up: func(tx *sql.Tx) error {
-			_, err := tx.Exec(`ALTER TABLE throws ADD COLUMN valid INTEGER NOT NULL DEFAULT 1`)
-			return err
-		},
-	},
-}
-
-func NewStore(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// NewStoreWithOptions opens the SQLite database at dbPath and migrates it to
+// the current schema
+func NewStoreWithOptions(dbPath string, opts SQLiteOptions) (*SQLStore, error) {
+	s, err := OpenSQLite(dbPath, opts)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := db.Ping(); err != nil {
-		return nil, err
-	}
-
-	s := &Store{db: db}
-	if err := s.initSchema(); err != nil {
+	if _, err := s.MigrateUp(); err != nil {
+		s.Close()
 		return nil, err
 	}
-
 	return s, nil
 }
 
-func (s *Store) initSchema() error {
-	// Create schema_version table first
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS schema_version (
-			version INTEGER PRIMARY KEY,
-			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		log.Printf("Error creating schema_version table: %v", err)
-		return err
-	}
-
-	// Create base tables (if not exists)
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS games (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		status TEXT NOT NULL,
-		total_points INTEGER NOT NULL,
-		best_of_sets INTEGER NOT NULL,
-		double_out INTEGER DEFAULT 0,
-		winner_id INTEGER,
-		current_player_index INTEGER DEFAULT 0,
-		current_throw_number INTEGER DEFAULT 0,
-		current_turn_points INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (winner_id) REFERENCES users(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS game_players (
-		game_id INTEGER NOT NULL,
-		user_id INTEGER NOT NULL,
-		player_order INTEGER NOT NULL,
-		sets_won INTEGER DEFAULT 0,
-		current_points INTEGER,
-		PRIMARY KEY (game_id, user_id),
-		FOREIGN KEY (game_id) REFERENCES games(id),
-		FOREIGN KEY (user_id) REFERENCES users(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS throws (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		game_id INTEGER NOT NULL,
-		user_id INTEGER NOT NULL,
-		points INTEGER NOT NULL,
-		multiplier INTEGER NOT NULL,
-		score_after INTEGER NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (game_id) REFERENCES games(id),
-		FOREIGN KEY (user_id) REFERENCES users(id)
-	);
-
-	-- Create indexes for better query performance
-	CREATE INDEX IF NOT EXISTS idx_games_status ON games(status);
-	CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner_id);
-	CREATE INDEX IF NOT EXISTS idx_game_players_user ON game_players(user_id);
-	CREATE INDEX IF NOT EXISTS idx_throws_game ON throws(game_id);
-	CREATE INDEX IF NOT EXISTS idx_throws_user ON throws(user_id);
-	CREATE INDEX IF NOT EXISTS idx_throws_created ON throws(created_at);
-	`
-
-	_, err = s.db.Exec(schema)
+// OpenSQLite opens the SQLite database at dbPath without migrating it, for
+// inspecting and changing the schema version
+func OpenSQLite(dbPath string, opts SQLiteOptions) (*SQLStore, error) {
+	s, err := openSQLite(dbPath, opts)
 	if err != nil {
-		log.Printf("Error initializing schema: %v", err)
-		return err
+		return nil, err
 	}
 
-	// Run migrations
-	for _, m := range migrations {
-		if err := s.runMigration(m); err != nil {
-			return fmt.Errorf("migration %d failed: %w", m.version, err)
-		}
+	if err := s.initSchemaVersion(); err != nil {
+		s.Close()
+		return nil, err
 	}
 
-	return nil
+	return s, nil
 }
 
-func (s *Store) runMigration(m migration) error {
-	// Check if already applied
-	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_version WHERE version = ?`, m.version).Scan(&count)
-	if err != nil {
-		return err
-	}
-	if count > 0 {
-		return nil // Already applied
-	}
-
-	// Run migration in transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	if err := m.up(tx); err != nil {
-		return err
-	}
-
-	// Record migration
-	_, err = tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, m.version)
-	if err != nil {
-		return err
+func (s *SQLStore) Close() error {
+	if s.read != s.db {
+		s.read.Close()
 	}
-
-	return tx.Commit()
+	return s.db.Close()
 }
 
-func (s *Store) Close() error {
-	return s.db.Close()
+// isUniqueViolation reports whether err is a failed UNIQUE constraint, e.g.
+// when another request inserted the same name after it was checked
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" // unique_violation
+	}
+	return isSQLiteUniqueViolation(err)
 }
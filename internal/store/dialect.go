@@ -0,0 +1,136 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect selects the SQL variant of the database behind a store. Queries are
+// written with ? placeholders and in the subset of SQL both databases share;
+// the few differences are handled here.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+func (d dialect) String() string {
+	if d == dialectPostgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// rebind rewrites ? placeholders to the numbered form PostgreSQL expects.
+// Question marks inside string literals are left alone.
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// args converts query arguments to the types stored in the schema. Flags
+// are INTEGER columns on both databases, but unlike SQLite the PostgreSQL
+// driver does not turn a bool into a number.
+func (d dialect) args(args []interface{}) []interface{} {
+	if d != dialectPostgres {
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, a := range args {
+		converted[i] = a
+		if b, ok := a.(bool); ok {
+			converted[i] = 0
+			if b {
+				converted[i] = 1
+			}
+		}
+	}
+	return converted
+}
+
+// bucketExpr returns the SQL expression truncating a timestamp column to the
+// start of its day, week (Monday) or month as a YYYY-MM-DD string
+func (d dialect) bucketExpr(bucket, column string) (string, error) {
+	if d == dialectPostgres {
+		switch bucket {
+		case "day", "week", "month":
+			return "to_char(date_trunc('" + bucket + "', " + column + "), 'YYYY-MM-DD')", nil
+		}
+		return "", ErrUnknownBucket
+	}
+
+	switch bucket {
+	case "day":
+		return "date(" + column + ")", nil
+	case "week":
+		return "date(" + column + ", 'weekday 0', '-6 days')", nil
+	case "month":
+		return "date(" + column + ", 'start of month')", nil
+	}
+	return "", ErrUnknownBucket
+}
+
+// conn is a database handle that rewrites queries for its dialect
+type conn struct {
+	*sql.DB
+	dialect dialect
+}
+
+func (c *conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(c.dialect.rebind(query), c.dialect.args(args)...)
+}
+
+func (c *conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(c.dialect.rebind(query), c.dialect.args(args)...)
+}
+
+func (c *conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(c.dialect.rebind(query), c.dialect.args(args)...)
+}
+
+func (c *conn) Begin() (*txn, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx, dialect: c.dialect}, nil
+}
+
+// txn is a transaction that rewrites queries for its dialect
+type txn struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (t *txn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+func (t *txn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+func (t *txn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), t.dialect.args(args)...)
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// ExportedThrow is one dart in the throws export
+type ExportedThrow struct {
+	GameID      int
+	SetNo       int
+	LegNo       int
+	VisitNo     int
+	DartNo      int
+	UserID      int
+	UserName    string
+	Segment     int // 0 for a miss, 25 for the bull
+	Multiplier  int
+	Score       int
+	ScoreBefore int
+	ScoreAfter  int
+	Bust        bool
+	CreatedAt   time.Time
+}
+
+// ExportedGame is one game in the games export
+type ExportedGame struct {
+	ID        int                  `json:"id"`
+	Status    models.GameStatus    `json:"status"`
+	Settings  models.GameSettings  `json:"settings"`
+	WinnerID  *int                 `json:"winner_id"`
+	CreatedAt time.Time            `json:"created_at"`
+	Players   []ExportedGamePlayer `json:"players"`
+}
+
+// ExportedGamePlayer is a player's result in an exported game
+type ExportedGamePlayer struct {
+	UserID   int    `json:"user_id"`
+	UserName string `json:"user_name"`
+	Order    int    `json:"order"`
+	SetsWon  int    `json:"sets_won"`
+}
+
+// ExportThrows calls fn for every throw matching the filter, ordered by game
+// and throw. Rows are read one at a time, so the export is never held in
+// memory; an error returned by fn stops the export.
+func (s *SQLStore) ExportThrows(filter ThrowFilter, fn func(*ExportedThrow) error) error {
+	// The score before a dart needs the whole leg, so the date range is
+	// applied after computing it
+	inner := ThrowFilter{UserID: filter.UserID, GameID: filter.GameID}
+	innerWhere, innerArgs := inner.where()
+	outer := ThrowFilter{From: filter.From, To: filter.To}
+	outerWhere, outerArgs := outer.where()
+
+	rows, err := s.read.Query(`
+		SELECT t.game_id, t.set_no, t.leg_no, t.visit_no, t.dart_no, t.user_id, COALESCE(u.name, 'Unknown'),
+			t.points, t.multiplier, t.valid, t.score_before, t.score_after, t.created_at
+		FROM (
+			SELECT t.id, t.game_id, t.set_no, t.leg_no, t.visit_no, t.dart_no, t.user_id,
+				t.points, t.multiplier, t.valid, t.score_after, t.created_at,
+				`+scoreBeforeExpr+` AS score_before
+			FROM throws t
+			JOIN games g ON t.game_id = g.id
+			WHERE `+innerWhere+`
+		) t
+		LEFT JOIN users u ON u.id = t.user_id
+		WHERE `+outerWhere+`
+		ORDER BY t.game_id, t.id`, append(innerArgs, outerArgs...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t ExportedThrow
+		var valid bool
+		if err := rows.Scan(&t.GameID, &t.SetNo, &t.LegNo, &t.VisitNo, &t.DartNo, &t.UserID, &t.UserName,
+			&t.Segment, &t.Multiplier, &valid, &t.ScoreBefore, &t.ScoreAfter, &t.CreatedAt); err != nil {
+			return err
+		}
+		t.Bust = !valid
+		if valid {
+			t.Score = t.Segment * t.Multiplier
+		}
+		if err := fn(&t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportGames calls fn for every game matching the filter, oldest first. The
+// filter's UserID selects games the user played in and the date range
+// applies to the game's creation time. Like ExportThrows, games are read one
+// at a time.
+func (s *SQLStore) ExportGames(filter ThrowFilter, fn func(*ExportedGame) error) error {
+	query := `
+		SELECT g.id, g.status, g.total_points, g.best_of_sets, g.double_out, g.winner_id, g.created_at,
+			gp.user_id, COALESCE(u.name, 'Unknown'), gp.player_order, gp.sets_won
+		FROM games g
+		JOIN game_players gp ON gp.game_id = g.id
+		LEFT JOIN users u ON u.id = gp.user_id
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.UserID != 0 {
+		query += ` AND g.id IN (SELECT game_id FROM game_players WHERE user_id = ?)`
+		args = append(args, filter.UserID)
+	}
+	if filter.GameID != 0 {
+		query += ` AND g.id = ?`
+		args = append(args, filter.GameID)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND g.created_at >= ?`
+		args = append(args, filter.From.UTC().Format(sqliteTimeFormat))
+	}
+	if !filter.To.IsZero() {
+		query += ` AND g.created_at < ?`
+		args = append(args, filter.To.UTC().Format(sqliteTimeFormat))
+	}
+	query += ` ORDER BY g.id, gp.player_order`
+
+	rows, err := s.read.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Rows are sorted by game, so a game is complete when the next one starts
+	var current *ExportedGame
+	for rows.Next() {
+		var g ExportedGame
+		var p ExportedGamePlayer
+		var doubleOut int
+		if err := rows.Scan(&g.ID, &g.Status, &g.Settings.TotalPoints, &g.Settings.BestOfSets, &doubleOut, &g.WinnerID, &g.CreatedAt,
+			&p.UserID, &p.UserName, &p.Order, &p.SetsWon); err != nil {
+			return err
+		}
+		g.Settings.DoubleOut = doubleOut == 1
+
+		if current == nil || current.ID != g.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			current = &g
+		}
+		current.Players = append(current.Players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
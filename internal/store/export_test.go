@@ -0,0 +1,100 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportThrows(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	playDarts(t, s, g.ID, bestOfThreeDarts(alice, bob))
+
+	var all []ExportedThrow
+	err := s.ExportThrows(ThrowFilter{}, func(e *ExportedThrow) error {
+		all = append(all, *e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to export throws: %v", err)
+	}
+	if len(all) != 13 {
+		t.Fatalf("Expected 13 throws, got %d", len(all))
+	}
+
+	var aliceThrows []ExportedThrow
+	err = s.ExportThrows(ThrowFilter{UserID: alice}, func(e *ExportedThrow) error {
+		aliceThrows = append(aliceThrows, *e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to export throws: %v", err)
+	}
+	if len(aliceThrows) != 6 {
+		t.Fatalf("Expected 6 throws for Alice, got %d", len(aliceThrows))
+	}
+	if first := aliceThrows[0]; first.UserName != "Alice" || first.Segment != 20 || first.Multiplier != 3 ||
+		first.Score != 60 || first.ScoreBefore != 101 || first.ScoreAfter != 41 {
+		t.Errorf("Unexpected first throw %+v", first)
+	}
+	// Set 2: T20 to 41, then a bust with T20
+	bust := aliceThrows[5]
+	if !bust.Bust || bust.SetNo != 2 || bust.DartNo != 2 || bust.Score != 0 || bust.ScoreBefore != 41 || bust.ScoreAfter != 101 {
+		t.Errorf("Unexpected bust throw %+v", bust)
+	}
+
+	// The date range is applied after the scores are computed
+	var none int
+	err = s.ExportThrows(ThrowFilter{From: time.Now().Add(time.Hour)}, func(*ExportedThrow) error {
+		none++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to export throws: %v", err)
+	}
+	if none != 0 {
+		t.Errorf("Expected no throws in the future, got %d", none)
+	}
+}
+
+func TestExportGames(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	other, err := s.CreateGame(301, 1, false, []int{bob})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	var games []ExportedGame
+	err = s.ExportGames(ThrowFilter{}, func(e *ExportedGame) error {
+		games = append(games, *e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to export games: %v", err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("Expected 2 games, got %d", len(games))
+	}
+	if games[0].ID != g.ID || len(games[0].Players) != 2 || games[0].Players[1].UserName != "Bob" {
+		t.Errorf("Unexpected first game %+v", games[0])
+	}
+	if games[1].ID != other.ID || games[1].Settings.TotalPoints != 301 || games[1].Settings.DoubleOut {
+		t.Errorf("Unexpected second game %+v", games[1])
+	}
+
+	// Only games Alice played in
+	games = nil
+	err = s.ExportGames(ThrowFilter{UserID: alice}, func(e *ExportedGame) error {
+		games = append(games, *e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to export games: %v", err)
+	}
+	if len(games) != 1 || games[0].ID != g.ID || len(games[0].Players) != 2 {
+		t.Errorf("Expected Alice's game with both players, got %+v", games)
+	}
+}
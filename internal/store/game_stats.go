@@ -1,14 +1,16 @@
 package store
 
-import (
-	"github.com/michaelschlottmann/darts-web/internal/models"
-)
+import "github.com/michaelschlottmann/darts-web/internal/models"
 
-// GameStatistics represents comprehensive statistics for a finished game
+// GameStatistics represents comprehensive statistics for a game. While the
+// game is in progress it also carries the visits of the current leg.
 type GameStatistics struct {
-	GameID          int                `json:"game_id"`
-	TotalSetsPlayed int                `json:"total_sets_played"`
-	Players         []PlayerGameStats  `json:"players"`
+	GameID          int               `json:"game_id"`
+	Status          models.GameStatus `json:"status"`
+	TotalSetsPlayed int               `json:"total_sets_played"`
+	Players         []PlayerGameStats `json:"players"`
+	Live            []LivePlayerStats `json:"live"`
+	CurrentLeg      *LegHistory       `json:"current_leg,omitempty"`
 }
 
 // PlayerGameStats contains all statistics for a single player in a game
@@ -35,92 +37,84 @@ type SetStats struct {
 	WonSet       bool    `json:"won_set"`
 }
 
-// GetGameStatistics calculates comprehensive statistics for a finished game
-func (s *Store) GetGameStatistics(gameID int) (*GameStatistics, error) {
-	// Fetch game details
-	game, err := s.GetGame(gameID)
+// GetGameStatistics calculates comprehensive statistics for a finished or
+// unfinished game, as loaded by GetGame
+func (s *SQLStore) GetGameStatistics(game *models.Game) (*GameStatistics, error) {
+	gameID := game.ID
+
+	// Sets are derived from the recorded legs. Each set is decided by a
+	// single leg, so the leg winner is the set winner.
+	legs, err := s.GetLegs(gameID)
 	if err != nil {
 		return nil, err
 	}
-	if game == nil {
-		return nil, nil
+	var setNumbers []int
+	setWinners := make(map[int]int)
+	for _, leg := range legs {
+		if len(setNumbers) == 0 || setNumbers[len(setNumbers)-1] != leg.SetNo {
+			setNumbers = append(setNumbers, leg.SetNo)
+		}
+		if leg.WinnerID != nil {
+			setWinners[leg.SetNo] = *leg.WinnerID
+		}
 	}
 
-	// Fetch all throws for this game, ordered by creation time
-	rows, err := s.db.Query(`
-		SELECT id, game_id, user_id, points, multiplier, score_after, valid, created_at
-		FROM throws
-		WHERE game_id = ?
-		ORDER BY created_at ASC
-	`, gameID)
+	// Throws per set and player from the aggregate table
+	setPlayerStats, err := s.queryPlayerSetStats(gameID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var throws []models.Throw
+	// Fetch all player names at once; deleted users show as "Unknown"
+	userNames := make(map[int]string)
+	rows, err := s.read.Query(`
+		SELECT gp.user_id, COALESCE(u.name, 'Unknown')
+		FROM game_players gp
+		LEFT JOIN users u ON u.id = gp.user_id
+		WHERE gp.game_id = ?`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	for rows.Next() {
-		var t models.Throw
-		var validInt int
-		if err := rows.Scan(&t.ID, &t.GameID, &t.UserID, &t.Points, &t.Multiplier, &t.ScoreAfter, &validInt, &t.CreatedAt); err != nil {
+		var userID int
+		var name string
+		if err := rows.Scan(&userID, &name); err != nil {
 			return nil, err
 		}
-		t.Valid = validInt == 1
-		throws = append(throws, t)
+		userNames[userID] = name
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	// Detect set boundaries and group throws by set
-	sets := detectSetBoundaries(throws, game.Settings.TotalPoints, game.Players)
-
 	// Calculate statistics for each player
 	playerStats := make(map[int]*PlayerGameStats)
 	for _, player := range game.Players {
-		// Fetch user name
-		var userName string
-		err := s.db.QueryRow("SELECT name FROM users WHERE id = ?", player.UserID).Scan(&userName)
-		if err != nil {
-			userName = "Unknown"
-		}
-
 		playerStats[player.UserID] = &PlayerGameStats{
 			UserID:       player.UserID,
-			UserName:     userName,
+			UserName:     userNames[player.UserID],
 			OverallStats: OverallStats{},
 			SetStats:     make([]SetStats, 0),
 		}
 	}
 
-	// Only calculate set stats if there are sets
-	if len(sets) > 0 {
-		// Calculate statistics for each set
-		for setNum, setThrows := range sets {
-			// Calculate per-player stats for this set
-			setPlayerStats := calculateSetPlayerStats(setThrows, game.Settings.TotalPoints)
-
-			// Determine who won this set (player who reached 0)
-			setWinner := findSetWinner(setThrows)
-
-			// Add set stats to each player
-			for userID, stats := range playerStats {
-				// Get player stats for this set (will be zero values if player didn't throw)
-				playerSetStat := setPlayerStats[userID]
-
-				setStats := SetStats{
-					SetNumber:    setNum + 1,
-					TotalThrows:  playerSetStat.totalThrows,
-					TotalPoints:  playerSetStat.totalPoints,
-					Average3Dart: playerSetStat.average3Dart,
-					WonSet:       userID == setWinner,
-				}
-				stats.SetStats = append(stats.SetStats, setStats)
-
-				// Accumulate overall stats
-				stats.OverallStats.TotalThrows += playerSetStat.totalThrows
-				stats.OverallStats.TotalPoints += playerSetStat.totalPoints
-			}
+	for _, setNo := range setNumbers {
+		for userID, stats := range playerStats {
+			// Zero values if player didn't throw in this set
+			playerSetStat := setPlayerStats[setNo][userID]
+
+			stats.SetStats = append(stats.SetStats, SetStats{
+				SetNumber:    setNo,
+				TotalThrows:  playerSetStat.totalThrows,
+				TotalPoints:  playerSetStat.totalPoints,
+				Average3Dart: playerSetStat.average3Dart,
+				WonSet:       userID == setWinners[setNo],
+			})
+
+			// Accumulate overall stats
+			stats.OverallStats.TotalThrows += playerSetStat.totalThrows
+			stats.OverallStats.TotalPoints += playerSetStat.totalPoints
 		}
 	}
 
@@ -139,72 +133,27 @@ func (s *Store) GetGameStatistics(gameID int) (*GameStatistics, error) {
 		}
 	}
 
-	return &GameStatistics{
-		GameID:          gameID,
-		TotalSetsPlayed: len(sets),
-		Players:         playersSlice,
-	}, nil
-}
-
-// detectSetBoundaries analyzes throw history to detect set boundaries
-// Returns a slice of sets, where each set is a slice of throws
-func detectSetBoundaries(throws []models.Throw, totalPoints int, players []models.GamePlayer) [][]models.Throw {
-	if len(throws) == 0 {
-		return [][]models.Throw{}
-	}
-
-	var sets [][]models.Throw
-	currentSet := []models.Throw{}
-
-	// Track each player's last known score to detect resets
-	playerScores := make(map[int]int)
-	for _, player := range players {
-		playerScores[player.UserID] = totalPoints
+	live, err := s.GetLiveStats(game)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, throw := range throws {
-		currentSet = append(currentSet, throw)
-
-		// Check if this throw caused a checkout (player reached 0)
-		if throw.ScoreAfter == 0 {
-			// Set complete - player won
-			sets = append(sets, currentSet)
-			currentSet = []models.Throw{}
-			// Reset all player scores for next set
-			for userID := range playerScores {
-				playerScores[userID] = totalPoints
-			}
-			continue
-		}
-
-		// Check for score reset (new set after previous set ended)
-		// This happens when a player's score jumps back to totalPoints after being lower
-		if prevScore, exists := playerScores[throw.UserID]; exists {
-			// If score is back at totalPoints and it was previously lower, new set started
-			if throw.ScoreAfter == totalPoints && prevScore < totalPoints && prevScore > 0 {
-				// Previous set ended, start new set
-				// Note: current throw belongs to new set, so don't include it in previous set
-				if len(currentSet) > 1 {
-					sets = append(sets, currentSet[:len(currentSet)-1])
-					currentSet = []models.Throw{throw}
-					// Reset tracking
-					for userID := range playerScores {
-						playerScores[userID] = totalPoints
-					}
-				}
-			}
+	var currentLeg *LegHistory
+	if game.Status != models.GameStatusFinished {
+		currentLeg, err = s.GetCurrentLegHistory(game)
+		if err != nil {
+			return nil, err
 		}
-
-		// Update player's score
-		playerScores[throw.UserID] = throw.ScoreAfter
 	}
 
-	// Add final set if it has throws
-	if len(currentSet) > 0 {
-		sets = append(sets, currentSet)
-	}
-
-	return sets
+	return &GameStatistics{
+		GameID:          gameID,
+		Status:          game.Status,
+		TotalSetsPlayed: len(setNumbers),
+		Players:         playersSlice,
+		Live:            live,
+		CurrentLeg:      currentLeg,
+	}, nil
 }
 
 // playerSetStats is a helper struct for calculating statistics
@@ -214,40 +163,32 @@ type playerSetStats struct {
 	average3Dart float64
 }
 
-// calculateSetPlayerStats calculates statistics for each player in a set
-func calculateSetPlayerStats(setThrows []models.Throw, totalPoints int) map[int]playerSetStats {
-	stats := make(map[int]playerSetStats)
-
-	for _, throw := range setThrows {
-		s := stats[throw.UserID]
-		s.totalThrows++
-		// Only include valid throws in point calculation
-		// Bust throws count toward throw total but not points
-		if throw.Valid {
-			s.totalPoints += throw.Points * throw.Multiplier
-		}
-		stats[throw.UserID] = s
+// queryPlayerSetStats reads a game's throw totals by set and player
+func (s *SQLStore) queryPlayerSetStats(gameID int) (map[int]map[int]playerSetStats, error) {
+	rows, err := s.read.Query(`
+		SELECT set_no, user_id, throws, points
+		FROM game_player_set_stats
+		WHERE game_id = ?
+	`, gameID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Calculate 3-dart averages
-	for userID, s := range stats {
-		if s.totalThrows > 0 {
-			s.average3Dart = (float64(s.totalPoints) / float64(s.totalThrows)) * 3
-			stats[userID] = s
+	stats := make(map[int]map[int]playerSetStats)
+	for rows.Next() {
+		var setNo, userID int
+		var ps playerSetStats
+		if err := rows.Scan(&setNo, &userID, &ps.totalThrows, &ps.totalPoints); err != nil {
+			return nil, err
 		}
-	}
-
-	return stats
-}
-
-// findSetWinner returns the user ID of the player who won the set
-// Returns 0 if no winner found (shouldn't happen in finished games)
-func findSetWinner(setThrows []models.Throw) int {
-	// Look for the throw that brought a player to exactly 0
-	for _, throw := range setThrows {
-		if throw.ScoreAfter == 0 {
-			return throw.UserID
+		if ps.totalThrows > 0 {
+			ps.average3Dart = (float64(ps.totalPoints) / float64(ps.totalThrows)) * 3
+		}
+		if stats[setNo] == nil {
+			stats[setNo] = make(map[int]playerSetStats)
 		}
+		stats[setNo][userID] = ps
 	}
-	return 0
+	return stats, rows.Err()
 }
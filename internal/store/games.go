@@ -6,24 +6,49 @@ import (
 	"github.com/michaelschlottmann/darts-web/internal/models"
 )
 
-func (s *Store) CreateGame(totalPoints, bestOf int, doubleOut bool, playerIDs []int) (*models.Game, error) {
+// CreateGame starts a game between active players. It returns
+// ErrUnknownPlayer if a player does not exist or is archived.
+func (s *SQLStore) CreateGame(totalPoints, bestOf int, doubleOut bool, playerIDs []int) (*models.Game, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	for _, uid := range playerIDs {
+		var active bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND archived_at IS NULL)`, uid).Scan(&active)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrUnknownPlayer
+		}
+	}
+
+	g, err := createGame(tx, totalPoints, bestOf, doubleOut, playerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func createGame(tx *txn, totalPoints, bestOf int, doubleOut bool, playerIDs []int) (*models.Game, error) {
 	// Create Game
 	doubleOutInt := 0
 	if doubleOut {
 		doubleOutInt = 1
 	}
-	res, err := tx.Exec(`INSERT INTO games (status, total_points, best_of_sets, double_out, current_player_index, current_throw_number) VALUES (?, ?, ?, ?, 0, 0)`,
-		models.GameStatusPending, totalPoints, bestOf, doubleOutInt)
+	var gameID int
+	err := tx.QueryRow(`INSERT INTO games (status, total_points, best_of_sets, double_out, current_player_index, current_throw_number) VALUES (?, ?, ?, ?, 0, 0) RETURNING id`,
+		models.GameStatusPending, totalPoints, bestOf, doubleOutInt).Scan(&gameID)
 	if err != nil {
 		return nil, err
 	}
-	gameID, _ := res.LastInsertId()
 
 	// Add Players
 	players := make([]models.GamePlayer, len(playerIDs))
@@ -40,12 +65,8 @@ func (s *Store) CreateGame(totalPoints, bestOf int, doubleOut bool, playerIDs []
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
-	}
-
 	return &models.Game{
-		ID:          int(gameID),
+		ID:          gameID,
 		Status:      models.GameStatusPending,
 		Settings:    models.GameSettings{TotalPoints: totalPoints, BestOfSets: bestOf, DoubleOut: doubleOut},
 		Players:     players,
@@ -53,7 +74,7 @@ func (s *Store) CreateGame(totalPoints, bestOf int, doubleOut bool, playerIDs []
 	}, nil
 }
 
-func (s *Store) GetGame(id int) (*models.Game, error) {
+func (s *SQLStore) GetGame(id int) (*models.Game, error) {
 	// Simple fetch, in production might join everything
 	// For MVP, structured manual queries
 
@@ -65,8 +86,8 @@ func (s *Store) GetGame(id int) (*models.Game, error) {
 	// Create struct to hold turn status if it's nil
 	g.CurrentTurn = &models.TurnStatus{}
 
-	err := s.db.QueryRow(`SELECT id, status, total_points, best_of_sets, double_out, winner_id, current_player_index, current_throw_number, current_turn_points, created_at FROM games WHERE id = ?`, id).
-		Scan(&g.ID, &statusStr, &g.Settings.TotalPoints, &g.Settings.BestOfSets, &doubleOutInt, &g.WinnerID, &g.CurrentTurn.PlayerIndex, &g.CurrentTurn.ThrowNumber, &g.CurrentTurn.CurrentTurnPoints, &g.CreatedAt)
+	err := s.read.QueryRow(`SELECT id, status, total_points, best_of_sets, double_out, winner_id, current_player_index, current_throw_number, current_turn_points, current_visit_number, created_at FROM games WHERE id = ?`, id).
+		Scan(&g.ID, &statusStr, &g.Settings.TotalPoints, &g.Settings.BestOfSets, &doubleOutInt, &g.WinnerID, &g.CurrentTurn.PlayerIndex, &g.CurrentTurn.ThrowNumber, &g.CurrentTurn.CurrentTurnPoints, &g.CurrentTurn.VisitNumber, &g.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found
 	}
@@ -77,7 +98,7 @@ func (s *Store) GetGame(id int) (*models.Game, error) {
 	g.Settings.DoubleOut = doubleOutInt != 0
 
 	// Players
-	rows, err := s.db.Query(`SELECT user_id, player_order, sets_won, current_points FROM game_players WHERE game_id = ? ORDER BY player_order`, id)
+	rows, err := s.read.Query(`SELECT user_id, player_order, sets_won, current_points FROM game_players WHERE game_id = ? ORDER BY player_order`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -94,17 +115,22 @@ func (s *Store) GetGame(id int) (*models.Game, error) {
 	return &g, nil
 }
 
-func (s *Store) SaveThrow(t *models.Throw) error {
-	validInt := 0
-	if t.Valid {
-		validInt = 1
+// SaveThrow stores a throw together with the leg and visit it belongs to
+func (s *SQLStore) SaveThrow(t *models.Throw) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveThrow(tx, t); err != nil {
+		return err
 	}
-	_, err := s.db.Exec(`INSERT INTO throws (game_id, user_id, points, multiplier, score_after, valid) VALUES (?, ?, ?, ?, ?, ?)`,
-		t.GameID, t.UserID, t.Points, t.Multiplier, t.ScoreAfter, validInt)
-	return err
+
+	return tx.Commit()
 }
 
-func (s *Store) UpdateGame(g *models.Game) error {
+func (s *SQLStore) UpdateGame(g *models.Game) error {
 	// Use transaction to ensure atomic updates
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -112,9 +138,69 @@ func (s *Store) UpdateGame(g *models.Game) error {
 	}
 	defer tx.Rollback()
 
+	if err := updateGame(tx, g); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordThrow saves a throw and the game state it produced in one
+// transaction, so statistics aggregates never miss a throw, and returns the
+// achievements the throw unlocked
+func (s *SQLStore) RecordThrow(t *models.Throw, g *models.Game) ([]models.Achievement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := saveThrow(tx, t); err != nil {
+		return nil, err
+	}
+	if err := updateGame(tx, g); err != nil {
+		return nil, err
+	}
+	achievements, err := evaluateAchievements(tx, t, g)
+	if err != nil {
+		return nil, err
+	}
+
+	return achievements, tx.Commit()
+}
+
+func saveThrow(tx *txn, t *models.Throw) error {
+	validInt := 0
+	if t.Valid {
+		validInt = 1
+	}
+	// Throws normally get the current time; imported ones bring their own
+	var createdAt interface{}
+	if !t.CreatedAt.IsZero() {
+		createdAt = t.CreatedAt.UTC().Format(sqliteTimeFormat)
+	}
+	err := tx.QueryRow(`INSERT INTO throws (game_id, user_id, points, multiplier, score_after, valid, set_no, leg_no, visit_no, dart_no, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP)) RETURNING id`,
+		t.GameID, t.UserID, t.Points, t.Multiplier, t.ScoreAfter, validInt, t.SetNo, t.LegNo, t.VisitNo, t.DartNo, createdAt).Scan(&t.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := recordThrowPosition(tx, t); err != nil {
+		return err
+	}
+
+	return addThrowToGameStats(tx, t)
+}
+
+func updateGame(tx *txn, g *models.Game) error {
+	var previousStatus string
+	if err := tx.QueryRow(`SELECT status FROM games WHERE id = ?`, g.ID).Scan(&previousStatus); err != nil {
+		return err
+	}
+
 	// Update Game Status
-	_, err = tx.Exec(`UPDATE games SET status = ?, winner_id = ?, current_player_index = ?, current_throw_number = ?, current_turn_points = ? WHERE id = ?`,
-		g.Status, g.WinnerID, g.CurrentTurn.PlayerIndex, g.CurrentTurn.ThrowNumber, g.CurrentTurn.CurrentTurnPoints, g.ID)
+	_, err := tx.Exec(`UPDATE games SET status = ?, winner_id = ?, current_player_index = ?, current_throw_number = ?, current_turn_points = ?, current_visit_number = ? WHERE id = ?`,
+		g.Status, g.WinnerID, g.CurrentTurn.PlayerIndex, g.CurrentTurn.ThrowNumber, g.CurrentTurn.CurrentTurnPoints, g.CurrentTurn.VisitNumber, g.ID)
 	if err != nil {
 		return err
 	}
@@ -128,5 +214,14 @@ func (s *Store) UpdateGame(g *models.Game) error {
 		}
 	}
 
-	return tx.Commit()
+	// Player statistics and ratings only cover finished games, and are
+	// saved with the throw that finished the game
+	if previousStatus != string(models.GameStatusFinished) && g.Status == models.GameStatusFinished {
+		if err := addGameToUserStats(tx, g.ID); err != nil {
+			return err
+		}
+		return applyGameRatings(tx, g.ID)
+	}
+
+	return nil
 }
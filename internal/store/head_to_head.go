@@ -0,0 +1,164 @@
+package store
+
+import (
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// HeadToHead compares two players over the finished games they both played,
+// including games with further players
+type HeadToHead struct {
+	GamesPlayed     int                `json:"games_played"`
+	Player          HeadToHeadPlayer   `json:"player"`
+	Opponent        HeadToHeadPlayer   `json:"opponent"`
+	SetDifferential int                `json:"set_differential"` // Player minus opponent
+	LegDifferential int                `json:"leg_differential"`
+	RecentResults   []HeadToHeadResult `json:"recent_results"`
+}
+
+// HeadToHeadPlayer contains one side's record in the shared games
+type HeadToHeadPlayer struct {
+	UserID       int     `json:"user_id"`
+	Wins         int     `json:"wins"`
+	SetsWon      int     `json:"sets_won"`
+	LegsWon      int     `json:"legs_won"`
+	Average3Dart float64 `json:"average_3_dart"`
+}
+
+// HeadToHeadResult is the outcome of one shared game
+type HeadToHeadResult struct {
+	GameID          int       `json:"game_id"`
+	CreatedAt       time.Time `json:"created_at"`
+	WinnerID        *int      `json:"winner_id,omitempty"`
+	PlayerSetsWon   int       `json:"player_sets_won"`
+	OpponentSetsWon int       `json:"opponent_sets_won"`
+	PlayerCount     int       `json:"player_count"`
+}
+
+// sharedGamesCTE selects the finished games both players took part in
+const sharedGamesCTE = `
+	WITH shared AS (
+		SELECT g.id AS game_id
+		FROM games g
+		JOIN game_players a ON a.game_id = g.id AND a.user_id = ?
+		JOIN game_players b ON b.game_id = g.id AND b.user_id = ?
+		WHERE g.status = ?
+	)`
+
+// GetHeadToHead returns the record of userID against otherID with up to
+// recentLimit of the latest shared games
+func (s *SQLStore) GetHeadToHead(userID, otherID, recentLimit int) (*HeadToHead, error) {
+	h2h := &HeadToHead{
+		Player:        HeadToHeadPlayer{UserID: userID},
+		Opponent:      HeadToHeadPlayer{UserID: otherID},
+		RecentResults: []HeadToHeadResult{},
+	}
+	sharedArgs := []interface{}{userID, otherID, models.GameStatusFinished}
+
+	// Results per game, newest first
+	rows, err := s.read.Query(sharedGamesCTE+`
+		SELECT g.id, g.created_at, g.winner_id, a.sets_won, b.sets_won,
+			(SELECT COUNT(*) FROM game_players gp WHERE gp.game_id = g.id)
+		FROM shared
+		JOIN games g ON g.id = shared.game_id
+		JOIN game_players a ON a.game_id = g.id AND a.user_id = ?
+		JOIN game_players b ON b.game_id = g.id AND b.user_id = ?
+		ORDER BY g.created_at DESC, g.id DESC`,
+		append(sharedArgs, userID, otherID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r HeadToHeadResult
+		if err := rows.Scan(&r.GameID, &r.CreatedAt, &r.WinnerID, &r.PlayerSetsWon, &r.OpponentSetsWon, &r.PlayerCount); err != nil {
+			return nil, err
+		}
+		h2h.GamesPlayed++
+		h2h.Player.SetsWon += r.PlayerSetsWon
+		h2h.Opponent.SetsWon += r.OpponentSetsWon
+		if r.WinnerID != nil {
+			switch *r.WinnerID {
+			case userID:
+				h2h.Player.Wins++
+			case otherID:
+				h2h.Opponent.Wins++
+			}
+		}
+		if len(h2h.RecentResults) < recentLimit {
+			h2h.RecentResults = append(h2h.RecentResults, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Legs won in the shared games
+	rows, err = s.read.Query(sharedGamesCTE+`
+		SELECT l.winner_id, COUNT(*)
+		FROM legs l
+		JOIN shared ON shared.game_id = l.game_id
+		WHERE l.winner_id IN (?, ?)
+		GROUP BY l.winner_id`,
+		append(sharedArgs, userID, otherID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var winnerID, legs int
+		if err := rows.Scan(&winnerID, &legs); err != nil {
+			return nil, err
+		}
+		if winnerID == userID {
+			h2h.Player.LegsWon = legs
+		} else {
+			h2h.Opponent.LegsWon = legs
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// 3-dart averages in the shared games
+	rows, err = s.read.Query(sharedGamesCTE+`
+		SELECT s.user_id, SUM(s.points), SUM(s.throws)
+		FROM game_player_set_stats s
+		JOIN shared ON shared.game_id = s.game_id
+		WHERE s.user_id IN (?, ?)
+		GROUP BY s.user_id`,
+		append(sharedArgs, userID, otherID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid, totalPoints, totalThrows int
+		if err := rows.Scan(&uid, &totalPoints, &totalThrows); err != nil {
+			return nil, err
+		}
+		average := 0.0
+		if totalThrows > 0 {
+			average = (float64(totalPoints) / float64(totalThrows)) * 3
+		}
+		if uid == userID {
+			h2h.Player.Average3Dart = average
+		} else {
+			h2h.Opponent.Average3Dart = average
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	h2h.SetDifferential = h2h.Player.SetsWon - h2h.Opponent.SetsWon
+	h2h.LegDifferential = h2h.Player.LegsWon - h2h.Opponent.LegsWon
+
+	return h2h, nil
+}
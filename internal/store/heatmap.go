@@ -0,0 +1,131 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// sqliteTimeFormat matches the format SQLite uses for CURRENT_TIMESTAMP, so
+// bound parameters compare correctly against created_at columns
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// ThrowFilter restricts throw based queries. Zero values are ignored.
+type ThrowFilter struct {
+	UserID int
+	GameID int
+	From   time.Time // Inclusive
+	To     time.Time // Exclusive
+}
+
+// where builds the WHERE clause for the filter. The throws table must be
+// aliased as t in the surrounding query.
+func (f ThrowFilter) where() (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if f.UserID != 0 {
+		conditions = append(conditions, "t.user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.GameID != 0 {
+		conditions = append(conditions, "t.game_id = ?")
+		args = append(args, f.GameID)
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, "t.created_at >= ?")
+		args = append(args, f.From.UTC().Format(sqliteTimeFormat))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "t.created_at < ?")
+		args = append(args, f.To.UTC().Format(sqliteTimeFormat))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Heatmap contains hit counts per board segment and ring for one player
+type Heatmap struct {
+	UserID     int           `json:"user_id"`
+	TotalDarts int           `json:"total_darts"`
+	Segments   []SegmentHits `json:"segments"`
+	Bull       BullHits      `json:"bull"`
+	Misses     int           `json:"misses"`
+}
+
+// SegmentHits contains the hits of one numbered segment (1-20)
+type SegmentHits struct {
+	Segment int `json:"segment"`
+	Singles int `json:"singles"`
+	Doubles int `json:"doubles"`
+	Trebles int `json:"trebles"`
+	Total   int `json:"total"`
+	// Share of all darts that landed in the treble of this segment, so
+	// T20 and T19 rates can be compared directly
+	TrebleRate float64 `json:"treble_rate"`
+}
+
+// BullHits contains hits on the outer (25) and inner (50) bull
+type BullHits struct {
+	Outer int `json:"outer"`
+	Inner int `json:"inner"`
+}
+
+// GetUserHeatmap counts where a player's darts landed. Busted darts are
+// included since they still hit the board.
+func (s *SQLStore) GetUserHeatmap(userID int, filter ThrowFilter) (*Heatmap, error) {
+	filter.UserID = userID
+	where, args := filter.where()
+
+	// Aggregated in SQL, so at most 62 rows are returned regardless of history size
+	rows, err := s.read.Query(`
+		SELECT t.points, t.multiplier, COUNT(*)
+		FROM throws t
+		WHERE `+where+`
+		GROUP BY t.points, t.multiplier`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heatmap := &Heatmap{UserID: userID, Segments: make([]SegmentHits, 20)}
+	for i := range heatmap.Segments {
+		heatmap.Segments[i].Segment = i + 1
+	}
+
+	for rows.Next() {
+		var points, multiplier, count int
+		if err := rows.Scan(&points, &multiplier, &count); err != nil {
+			return nil, err
+		}
+		heatmap.TotalDarts += count
+
+		switch {
+		case points == 0:
+			heatmap.Misses += count
+		case points == 25 && multiplier == 2:
+			heatmap.Bull.Inner += count
+		case points == 25:
+			heatmap.Bull.Outer += count
+		case points >= 1 && points <= 20:
+			seg := &heatmap.Segments[points-1]
+			switch multiplier {
+			case 1:
+				seg.Singles += count
+			case 2:
+				seg.Doubles += count
+			case 3:
+				seg.Trebles += count
+			}
+			seg.Total += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if heatmap.TotalDarts > 0 {
+		for i := range heatmap.Segments {
+			heatmap.Segments[i].TrebleRate = float64(heatmap.Segments[i].Trebles) / float64(heatmap.TotalDarts) * 100
+		}
+	}
+
+	return heatmap, nil
+}
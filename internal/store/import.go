@@ -0,0 +1,126 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/game"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// ImportGame is a complete game read from an archive
+type ImportGame struct {
+	Ref         string // Identifies the game in error reports
+	PlayedAt    time.Time
+	TotalPoints int
+	BestOf      int
+	DoubleOut   bool
+	Players     []string // Names in throwing order
+	Throws      []ImportThrow
+}
+
+// ImportThrow is a single dart read from an archive
+type ImportThrow struct {
+	Row        int // Position in the archive, for error reports
+	Player     string
+	Points     int
+	Multiplier int
+	ThrownAt   time.Time // Optional, defaults to the game's PlayedAt
+}
+
+// ImportError describes why a game of an archive was not imported. Row is
+// 0 for errors concerning the whole game.
+type ImportError struct {
+	Game    string `json:"game"`
+	Row     int    `json:"row,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ImportError) Error() string {
+	if e.Row > 0 {
+		return fmt.Sprintf("game %s, row %d: %s", e.Game, e.Row, e.Message)
+	}
+	return fmt.Sprintf("game %s: %s", e.Game, e.Message)
+}
+
+// ImportGame replays an archived game through the engine and stores it with
+// its players in one transaction. Players are matched by name, ignoring case
+// like CreateUser, and created if they don't exist. Any invalid throw or a game that is not finished after
+// the last throw rejects the whole game with an *ImportError.
+func (s *SQLStore) ImportGame(ig *ImportGame) (*models.Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userIDs := make(map[string]int)
+	playerIDs := make([]int, len(ig.Players))
+	for i, name := range ig.Players {
+		id, err := findOrCreateUser(tx, name)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(playerIDs[:i], id) {
+			return nil, &ImportError{Game: ig.Ref, Message: fmt.Sprintf("player %q is listed twice", name)}
+		}
+		userIDs[nameKey(name)] = id
+		playerIDs[i] = id
+	}
+
+	g, err := createGame(tx, ig.TotalPoints, ig.BestOf, ig.DoubleOut, playerIDs)
+	if err != nil {
+		return nil, err
+	}
+	if !ig.PlayedAt.IsZero() {
+		g.CreatedAt = ig.PlayedAt.UTC()
+		if _, err := tx.Exec(`UPDATE games SET created_at = ? WHERE id = ?`, g.CreatedAt.Format(sqliteTimeFormat), g.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	engine := game.NewEngine()
+	for _, it := range ig.Throws {
+		userID, ok := userIDs[nameKey(it.Player)]
+		if !ok {
+			return nil, &ImportError{Game: ig.Ref, Row: it.Row, Message: fmt.Sprintf("player %q is not in the game", it.Player)}
+		}
+		throw, err := engine.ProcessThrow(g, userID, it.Points, it.Multiplier)
+		if err != nil {
+			return nil, &ImportError{Game: ig.Ref, Row: it.Row, Message: err.Error()}
+		}
+		throw.CreatedAt = it.ThrownAt
+		if throw.CreatedAt.IsZero() {
+			throw.CreatedAt = ig.PlayedAt
+		}
+		if err := saveThrow(tx, throw); err != nil {
+			return nil, err
+		}
+		if err := updateGame(tx, g); err != nil {
+			return nil, err
+		}
+	}
+
+	if g.Status != models.GameStatusFinished {
+		return nil, &ImportError{Game: ig.Ref, Message: "game is not finished after the last throw"}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// findOrCreateUser returns the ID of the user with the given name, ignoring
+// case, creating the user if necessary
+func findOrCreateUser(tx *txn, name string) (int, error) {
+	name = NormaliseName(name)
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE name_key = ?`, nameKey(name)).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`INSERT INTO users (name, name_key) VALUES (?, ?) RETURNING id`, name, nameKey(name)).Scan(&id)
+	}
+	return id, err
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// LeaderboardEntry is one player's position on a leaderboard
+type LeaderboardEntry struct {
+	Rank     int     `json:"rank"`
+	UserID   int     `json:"user_id"`
+	UserName string  `json:"user_name"`
+	Value    float64 `json:"value"`
+	Games    int     `json:"games"`
+}
+
+// leaderboardMetrics holds the per-player value of each supported metric.
+// Every query selects user_id and value and may use the period_games (g)
+// and player_games CTEs.
+var leaderboardMetrics = map[string]string{
+	MetricAverage3Dart: `
+		SELECT s.user_id, SUM(s.points) * 3.0 / SUM(s.throws) AS value
+		FROM game_player_set_stats s
+		JOIN period_games g ON s.game_id = g.id
+		GROUP BY s.user_id`,
+	MetricWinRate: `
+		SELECT user_id, wins * 100.0 / games AS value
+		FROM player_games`,
+	MetricMaximums180: `
+		SELECT v.user_id, COUNT(*) AS value
+		FROM visits v
+		JOIN period_games g ON v.game_id = g.id
+		WHERE v.bust = 0 AND v.score_before - v.score_after = 180
+		GROUP BY v.user_id`,
+	MetricHighestCheckout: `
+		SELECT v.user_id, MAX(v.score_before) AS value
+		FROM visits v
+		JOIN period_games g ON v.game_id = g.id
+		WHERE v.checkout = 1
+		GROUP BY v.user_id`,
+	MetricCheckoutPercentage: `
+		SELECT user_id,
+			SUM(CASE WHEN valid = 1 AND score_after = 0 THEN 1 ELSE 0 END) * 100.0 / COUNT(*) AS value
+		FROM (
+			SELECT t.user_id, t.valid, t.score_after, ` + scoreBeforeExpr + ` AS score_before
+			FROM throws t
+			JOIN period_games g ON t.game_id = g.id
+		) darts
+		WHERE ` + checkoutAttemptCondition + `
+		GROUP BY user_id`,
+}
+
+// GetLeaderboard ranks all players by a metric over the finished games
+// created since the given time (zero for all time). Players with fewer than
+// minGames finished games in the period and archived players are left out.
+func (s *SQLStore) GetLeaderboard(metric string, since time.Time, minGames int) ([]LeaderboardEntry, error) {
+	metricQuery, ok := leaderboardMetrics[metric]
+	if !ok {
+		return nil, ErrUnknownMetric
+	}
+
+	periodCondition := ""
+	args := []interface{}{models.GameStatusFinished}
+	if !since.IsZero() {
+		periodCondition = " AND created_at >= ?"
+		args = append(args, since.UTC().Format(sqliteTimeFormat))
+	}
+	args = append(args, minGames)
+
+	rows, err := s.read.Query(`
+		WITH period_games AS (
+			SELECT id, total_points, winner_id
+			FROM games
+			WHERE status = ?`+periodCondition+`
+		),
+		player_games AS (
+			SELECT gp.user_id,
+				COUNT(*) AS games,
+				SUM(CASE WHEN pg.winner_id = gp.user_id THEN 1 ELSE 0 END) AS wins
+			FROM game_players gp
+			JOIN period_games pg ON pg.id = gp.game_id
+			GROUP BY gp.user_id
+			HAVING COUNT(*) >= ?
+		),
+		metric AS (`+metricQuery+`
+		)
+		SELECT pg.user_id, u.name, COALESCE(m.value, 0) AS value, pg.games
+		FROM player_games pg
+		JOIN users u ON u.id = pg.user_id
+		LEFT JOIN metric m ON m.user_id = pg.user_id
+		WHERE u.archived_at IS NULL
+		ORDER BY value DESC, u.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.UserName, &e.Value, &e.Games); err != nil {
+			return nil, err
+		}
+		// Equal values share a rank
+		e.Rank = len(entries) + 1
+		if len(entries) > 0 && entries[len(entries)-1].Value == e.Value {
+			e.Rank = entries[len(entries)-1].Rank
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
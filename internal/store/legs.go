@@ -0,0 +1,321 @@
+package store
+
+import (
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// backfillLegsAndVisits assigns positions to historic throws using the set
+// boundary heuristic and writes the matching legs and visits rows.
+func backfillLegsAndVisits(tx *txn) error {
+	type gameInfo struct {
+		id                 int
+		status             string
+		totalPoints        int
+		currentThrowNumber int
+	}
+
+	rows, err := tx.Query(`SELECT id, status, total_points, current_throw_number FROM games ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var games []gameInfo
+	for rows.Next() {
+		var g gameInfo
+		if err := rows.Scan(&g.id, &g.status, &g.totalPoints, &g.currentThrowNumber); err != nil {
+			rows.Close()
+			return err
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range games {
+		players, err := queryGamePlayers(tx, g.id)
+		if err != nil {
+			return err
+		}
+
+		throws, err := queryThrowsByID(tx, g.id)
+		if err != nil {
+			return err
+		}
+
+		sets := detectSetBoundaries(throws, g.totalPoints, players)
+		visitNumber := 0
+		for i, set := range sets {
+			visitNumber = 0
+			for j := range set {
+				t := &set[j]
+				if j == 0 || startsNewVisit(set[j-1], *t) {
+					visitNumber++
+					t.DartNo = 1
+				} else {
+					t.DartNo = set[j-1].DartNo + 1
+				}
+				t.SetNo = i + 1
+				t.LegNo = 1
+				t.VisitNo = visitNumber
+
+				if _, err := tx.Exec(`UPDATE throws SET set_no = ?, leg_no = ?, visit_no = ?, dart_no = ? WHERE id = ?`,
+					t.SetNo, t.LegNo, t.VisitNo, t.DartNo, t.ID); err != nil {
+					return err
+				}
+				if err := recordThrowPosition(tx, t); err != nil {
+					return err
+				}
+			}
+		}
+
+		if g.status == string(models.GameStatusFinished) || len(throws) == 0 {
+			continue
+		}
+
+		// Restore the visit counter of unfinished games. A checkout as the
+		// last throw means the next set has started without any visits yet,
+		// and a partially thrown visit is still in progress.
+		last := throws[len(throws)-1]
+		switch {
+		case last.Valid && last.ScoreAfter == 0:
+			visitNumber = 0
+		case g.currentThrowNumber > 0:
+			visitNumber--
+		}
+		if _, err := tx.Exec(`UPDATE games SET current_visit_number = ? WHERE id = ?`, visitNumber, g.id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startsNewVisit reports whether cur begins a new visit after prev. A visit
+// ends after three darts, on a bust or when the other player steps up.
+func startsNewVisit(prev, cur models.Throw) bool {
+	return cur.UserID != prev.UserID || prev.DartNo >= 3 || !prev.Valid
+}
+
+func queryGamePlayers(tx *txn, gameID int) ([]models.GamePlayer, error) {
+	rows, err := tx.Query(`SELECT user_id, player_order FROM game_players WHERE game_id = ? ORDER BY player_order`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []models.GamePlayer
+	for rows.Next() {
+		var p models.GamePlayer
+		if err := rows.Scan(&p.UserID, &p.Order); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+func queryThrowsByID(tx *txn, gameID int) ([]models.Throw, error) {
+	rows, err := tx.Query(`
+		SELECT id, game_id, user_id, points, multiplier, score_after, valid, created_at
+		FROM throws
+		WHERE game_id = ?
+		ORDER BY id ASC
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var throws []models.Throw
+	for rows.Next() {
+		var t models.Throw
+		var validInt int
+		if err := rows.Scan(&t.ID, &t.GameID, &t.UserID, &t.Points, &t.Multiplier, &t.ScoreAfter, &validInt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Valid = validInt == 1
+		throws = append(throws, t)
+	}
+	return throws, rows.Err()
+}
+
+// detectSetBoundaries analyzes throw history to detect set boundaries
+// Returns a slice of sets, where each set is a slice of throws
+// Only used to backfill games recorded before throws stored their set number
+func detectSetBoundaries(throws []models.Throw, totalPoints int, players []models.GamePlayer) [][]models.Throw {
+	if len(throws) == 0 {
+		return [][]models.Throw{}
+	}
+
+	var sets [][]models.Throw
+	currentSet := []models.Throw{}
+
+	// Track each player's last known score to detect resets
+	playerScores := make(map[int]int)
+	for _, player := range players {
+		playerScores[player.UserID] = totalPoints
+	}
+
+	for _, throw := range throws {
+		currentSet = append(currentSet, throw)
+
+		// Check if this throw caused a checkout (player reached 0)
+		if throw.ScoreAfter == 0 {
+			// Set complete - player won
+			sets = append(sets, currentSet)
+			currentSet = []models.Throw{}
+			// Reset all player scores for next set
+			for userID := range playerScores {
+				playerScores[userID] = totalPoints
+			}
+			continue
+		}
+
+		// Check for score reset (new set after previous set ended)
+		// This happens when a player's score jumps back to totalPoints after being lower
+		if prevScore, exists := playerScores[throw.UserID]; exists {
+			// If score is back at totalPoints and it was previously lower, new set started
+			if throw.ScoreAfter == totalPoints && prevScore < totalPoints && prevScore > 0 {
+				// Previous set ended, start new set
+				// Note: current throw belongs to new set, so don't include it in previous set
+				if len(currentSet) > 1 {
+					sets = append(sets, currentSet[:len(currentSet)-1])
+					currentSet = []models.Throw{throw}
+					// Reset tracking
+					for userID := range playerScores {
+						playerScores[userID] = totalPoints
+					}
+				}
+			}
+		}
+
+		// Update player's score
+		playerScores[throw.UserID] = throw.ScoreAfter
+	}
+
+	// Add final set if it has throws
+	if len(currentSet) > 0 {
+		sets = append(sets, currentSet)
+	}
+
+	return sets
+}
+
+// recordThrowPosition creates or updates the leg and visit rows for a throw
+// that has already been inserted into the throws table.
+func recordThrowPosition(tx *txn, t *models.Throw) error {
+	_, err := tx.Exec(`
+		INSERT INTO legs (game_id, set_no, leg_no, created_at)
+		VALUES (?, ?, ?, (SELECT created_at FROM throws WHERE id = ?))
+		ON CONFLICT DO NOTHING`,
+		t.GameID, t.SetNo, t.LegNo, t.ID)
+	if err != nil {
+		return err
+	}
+
+	var legID int
+	err = tx.QueryRow(`SELECT id FROM legs WHERE game_id = ? AND set_no = ? AND leg_no = ?`,
+		t.GameID, t.SetNo, t.LegNo).Scan(&legID)
+	if err != nil {
+		return err
+	}
+
+	// A bust reports the score at the start of the visit, a valid throw the
+	// score after it. Only the first dart of a visit creates the row, so only
+	// its score_before is used.
+	scoreBefore := t.ScoreAfter
+	if t.Valid {
+		scoreBefore += t.Points * t.Multiplier
+	}
+	_, err = tx.Exec(`
+		INSERT INTO visits (leg_id, game_id, user_id, visit_no, score_before, score_after, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, (SELECT created_at FROM throws WHERE id = ?))
+		ON CONFLICT DO NOTHING`,
+		legID, t.GameID, t.UserID, t.VisitNo, scoreBefore, scoreBefore, t.ID)
+	if err != nil {
+		return err
+	}
+
+	bustInt := 0
+	if !t.Valid {
+		bustInt = 1
+	}
+	checkout := t.Valid && t.ScoreAfter == 0
+	checkoutInt := 0
+	if checkout {
+		checkoutInt = 1
+	}
+	_, err = tx.Exec(`
+		UPDATE visits
+		SET score_after = ?, darts = darts + 1, bust = CASE WHEN ? = 1 THEN 1 ELSE bust END, checkout = ?
+		WHERE leg_id = ? AND visit_no = ?`,
+		t.ScoreAfter, bustInt, checkoutInt, legID, t.VisitNo)
+	if err != nil {
+		return err
+	}
+
+	if checkout {
+		_, err = tx.Exec(`
+			UPDATE legs
+			SET winner_id = ?, finished_at = (SELECT created_at FROM throws WHERE id = ?)
+			WHERE id = ?`,
+			t.UserID, t.ID, legID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetLegs returns all legs of a game in playing order
+func (s *SQLStore) GetLegs(gameID int) ([]models.Leg, error) {
+	rows, err := s.read.Query(`
+		SELECT id, game_id, set_no, leg_no, winner_id, created_at, finished_at
+		FROM legs
+		WHERE game_id = ?
+		ORDER BY set_no, leg_no
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var legs []models.Leg
+	for rows.Next() {
+		var l models.Leg
+		if err := rows.Scan(&l.ID, &l.GameID, &l.SetNo, &l.LegNo, &l.WinnerID, &l.CreatedAt, &l.FinishedAt); err != nil {
+			return nil, err
+		}
+		legs = append(legs, l)
+	}
+	return legs, rows.Err()
+}
+
+// GetLegVisits returns the visits of one leg in playing order
+func (s *SQLStore) GetLegVisits(gameID, setNo, legNo int) ([]models.Visit, error) {
+	rows, err := s.read.Query(`
+		SELECT v.id, v.leg_id, v.game_id, v.user_id, v.visit_no, v.score_before, v.score_after,
+			v.darts, v.bust, v.checkout, v.created_at
+		FROM visits v
+		JOIN legs l ON l.id = v.leg_id
+		WHERE l.game_id = ? AND l.set_no = ? AND l.leg_no = ?
+		ORDER BY v.visit_no
+	`, gameID, setNo, legNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	visits := []models.Visit{}
+	for rows.Next() {
+		var v models.Visit
+		if err := rows.Scan(&v.ID, &v.LegID, &v.GameID, &v.UserID, &v.VisitNo, &v.ScoreBefore, &v.ScoreAfter,
+			&v.Darts, &v.Bust, &v.Checkout, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		visits = append(visits, v)
+	}
+	return visits, rows.Err()
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/game"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+type testDart struct {
+	userID, points, multiplier int
+}
+
+// playDarts runs darts through the engine and persists them like HandleThrow
+func playDarts(t *testing.T, s *SQLStore, gameID int, darts []testDart) {
+	t.Helper()
+	engine := game.NewEngine()
+	for i, d := range darts {
+		g, err := s.GetGame(gameID)
+		if err != nil {
+			t.Fatalf("Failed to load game: %v", err)
+		}
+		throw, err := engine.ProcessThrow(g, d.userID, d.points, d.multiplier)
+		if err != nil {
+			t.Fatalf("Dart %d: ProcessThrow() error = %v", i, err)
+		}
+		if err := s.SaveThrow(throw); err != nil {
+			t.Fatalf("Dart %d: Failed to save throw: %v", i, err)
+		}
+		if err := s.UpdateGame(g); err != nil {
+			t.Fatalf("Dart %d: Failed to update game: %v", i, err)
+		}
+	}
+}
+
+// bestOfThreeDarts is a 101 best-of-3 where Alice (1) wins set 1, busts once
+// in set 2 and Bob (2) wins set 2
+func bestOfThreeDarts(alice, bob int) []testDart {
+	return []testDart{
+		// Set 1
+		{alice, 20, 3}, {alice, 1, 1}, {alice, 0, 1}, // 101 -> 40
+		{bob, 20, 1}, {bob, 20, 1}, {bob, 20, 1}, // 101 -> 41
+		{alice, 20, 2}, // checkout
+		// Set 2
+		{bob, 20, 3}, {bob, 1, 1}, {bob, 0, 1}, // 101 -> 40
+		{alice, 20, 3}, {alice, 20, 3}, // 101 -> 41 -> bust
+		{bob, 20, 2}, // checkout
+	}
+}
+
+func setupLegsGame(t *testing.T) (*SQLStore, *models.Game, int, int) {
+	t.Helper()
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	alice, err := s.CreateUser("Alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := s.CreateUser("Bob")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	g, err := s.CreateGame(101, 3, true, []int{alice.ID, bob.ID})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	return s, g, alice.ID, bob.ID
+}
+
+func TestSaveThrow_RecordsLegsAndVisits(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	playDarts(t, s, g.ID, bestOfThreeDarts(alice, bob))
+
+	legs, err := s.GetLegs(g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get legs: %v", err)
+	}
+	if len(legs) != 2 {
+		t.Fatalf("Expected 2 legs, got %d", len(legs))
+	}
+	if legs[0].WinnerID == nil || *legs[0].WinnerID != alice {
+		t.Errorf("Expected Alice to win leg 1, got %v", legs[0].WinnerID)
+	}
+	if legs[1].WinnerID == nil || *legs[1].WinnerID != bob {
+		t.Errorf("Expected Bob to win leg 2, got %v", legs[1].WinnerID)
+	}
+
+	var visits, busts, checkouts int
+	err = s.db.QueryRow(`SELECT COUNT(*), SUM(bust), SUM(checkout) FROM visits WHERE game_id = ?`, g.ID).
+		Scan(&visits, &busts, &checkouts)
+	if err != nil {
+		t.Fatalf("Failed to count visits: %v", err)
+	}
+	if visits != 6 || busts != 1 || checkouts != 2 {
+		t.Errorf("Expected 6 visits, 1 bust, 2 checkouts, got %d, %d, %d", visits, busts, checkouts)
+	}
+
+	var scoreBefore, scoreAfter, darts int
+	err = s.db.QueryRow(`
+		SELECT v.score_before, v.score_after, v.darts
+		FROM visits v JOIN legs l ON v.leg_id = l.id
+		WHERE v.game_id = ? AND l.set_no = 2 AND v.visit_no = 2`, g.ID).
+		Scan(&scoreBefore, &scoreAfter, &darts)
+	if err != nil {
+		t.Fatalf("Failed to load bust visit: %v", err)
+	}
+	if scoreBefore != 101 || scoreAfter != 101 || darts != 2 {
+		t.Errorf("Expected bust visit 101 -> 101 in 2 darts, got %d -> %d in %d", scoreBefore, scoreAfter, darts)
+	}
+
+	stats, err := gameStatistics(t, s, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get statistics: %v", err)
+	}
+	if stats.TotalSetsPlayed != 2 {
+		t.Errorf("Expected 2 sets played, got %d", stats.TotalSetsPlayed)
+	}
+	aliceStats := stats.Players[0]
+	if !aliceStats.SetStats[0].WonSet || aliceStats.SetStats[1].WonSet {
+		t.Errorf("Expected Alice to win only set 1, got %+v", aliceStats.SetStats)
+	}
+	if aliceStats.OverallStats.TotalThrows != 6 || aliceStats.OverallStats.TotalPoints != 161 {
+		t.Errorf("Expected Alice 6 throws for 161 points, got %+v", aliceStats.OverallStats)
+	}
+}
+
+func TestBackfillLegsAndVisits(t *testing.T) {
+	// Throws without positions only exist in SQLite databases from old releases
+	skipOnPostgres(t)
+
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	playDarts(t, s, g.ID, bestOfThreeDarts(alice, bob)[:11])
+
+	// Capture the positions written at throw time, then strip them to
+	// simulate a database from before the migration
+	type position struct{ set, leg, visit, dart int }
+	var want []position
+	rows, err := s.db.Query(`SELECT set_no, leg_no, visit_no, dart_no FROM throws WHERE game_id = ? ORDER BY id`, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to query throws: %v", err)
+	}
+	for rows.Next() {
+		var p position
+		if err := rows.Scan(&p.set, &p.leg, &p.visit, &p.dart); err != nil {
+			t.Fatalf("Failed to scan throw: %v", err)
+		}
+		want = append(want, p)
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(`
+		DELETE FROM visits;
+		DELETE FROM legs;
+		UPDATE throws SET set_no = 0, leg_no = 0, visit_no = 0, dart_no = 0;
+		UPDATE games SET current_visit_number = 0;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to reset positions: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := backfillLegsAndVisits(tx); err != nil {
+		tx.Rollback()
+		t.Fatalf("Backfill failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit backfill: %v", err)
+	}
+
+	rows, err = s.db.Query(`SELECT set_no, leg_no, visit_no, dart_no FROM throws WHERE game_id = ? ORDER BY id`, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to query throws: %v", err)
+	}
+	defer rows.Close()
+	i := 0
+	for rows.Next() {
+		var got position
+		if err := rows.Scan(&got.set, &got.leg, &got.visit, &got.dart); err != nil {
+			t.Fatalf("Failed to scan throw: %v", err)
+		}
+		if got != want[i] {
+			t.Errorf("Throw %d: expected %+v, got %+v", i, want[i], got)
+		}
+		i++
+	}
+
+	legs, err := s.GetLegs(g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get legs: %v", err)
+	}
+	if len(legs) != 2 {
+		t.Errorf("Expected 2 legs after backfill, got %d", len(legs))
+	}
+
+	// Alice is mid-visit in set 2 after Bob's completed visit
+	restored, err := s.GetGame(g.ID)
+	if err != nil {
+		t.Fatalf("Failed to load game: %v", err)
+	}
+	if restored.CurrentTurn.VisitNumber != 1 {
+		t.Errorf("Expected visit number 1 to be restored, got %d", restored.CurrentTurn.VisitNumber)
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import "github.com/michaelschlottmann/darts-web/internal/models"
+
+// LivePlayerStats is the compact running summary of a player in a game,
+// shown on the scoreboard while the game is in progress
+type LivePlayerStats struct {
+	UserID           int     `json:"user_id"`
+	Average3Dart     float64 `json:"average_3_dart"`
+	LegDarts         int     `json:"leg_darts"`        // Darts thrown in the current leg
+	LastVisitScore   int     `json:"last_visit_score"` // 0 after a bust
+	CheckoutAttempts int     `json:"checkout_attempts"`
+	CheckoutHits     int     `json:"checkout_hits"`
+}
+
+// LegHistory is the visit history of a single leg
+type LegHistory struct {
+	SetNo  int            `json:"set_no"`
+	LegNo  int            `json:"leg_no"`
+	Visits []models.Visit `json:"visits"`
+}
+
+// currentLeg returns the set and leg being played. Every finished set was won
+// by exactly one player, so a finished game ends in the set numbered by the
+// total sets won and an unfinished game plays the one after it.
+func currentLeg(g *models.Game) (int, int) {
+	setNo := 0
+	for _, p := range g.Players {
+		setNo += p.SetsWon
+	}
+	if g.Status != models.GameStatusFinished {
+		setNo++
+	}
+	return setNo, 1
+}
+
+// GetCurrentLegHistory returns the visits of the leg being played. A leg
+// that has not been started yet has no visits.
+func (s *SQLStore) GetCurrentLegHistory(g *models.Game) (*LegHistory, error) {
+	setNo, legNo := currentLeg(g)
+	visits, err := s.GetLegVisits(g.ID, setNo, legNo)
+	if err != nil {
+		return nil, err
+	}
+	return &LegHistory{SetNo: setNo, LegNo: legNo, Visits: visits}, nil
+}
+
+// GetLiveStats returns the running summary of every player in a game, in
+// player order. It works for unfinished games as well as finished ones.
+func (s *SQLStore) GetLiveStats(g *models.Game) ([]LivePlayerStats, error) {
+	stats := make(map[int]*LivePlayerStats)
+	for _, p := range g.Players {
+		stats[p.UserID] = &LivePlayerStats{UserID: p.UserID}
+	}
+
+	// Running 3-dart average over all sets
+	rows, err := s.read.Query(`
+		SELECT user_id, SUM(points), SUM(throws)
+		FROM game_player_set_stats
+		WHERE game_id = ?
+		GROUP BY user_id`, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, points, throws int
+		if err := rows.Scan(&userID, &points, &throws); err != nil {
+			return nil, err
+		}
+		if ls, ok := stats[userID]; ok && throws > 0 {
+			ls.Average3Dart = (float64(points) / float64(throws)) * 3
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Darts thrown in the current leg
+	setNo, legNo := currentLeg(g)
+	rows, err = s.read.Query(`
+		SELECT v.user_id, SUM(v.darts)
+		FROM visits v
+		JOIN legs l ON l.id = v.leg_id
+		WHERE l.game_id = ? AND l.set_no = ? AND l.leg_no = ?
+		GROUP BY v.user_id`, g.ID, setNo, legNo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, darts int
+		if err := rows.Scan(&userID, &darts); err != nil {
+			return nil, err
+		}
+		if ls, ok := stats[userID]; ok {
+			ls.LegDarts = darts
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Score of each player's latest visit. A bust keeps the score of the
+	// start of the visit, so it scores 0.
+	rows, err = s.read.Query(`
+		SELECT user_id, score_before - score_after
+		FROM visits
+		WHERE id IN (SELECT MAX(id) FROM visits WHERE game_id = ? GROUP BY user_id)`, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, score int
+		if err := rows.Scan(&userID, &score); err != nil {
+			return nil, err
+		}
+		if ls, ok := stats[userID]; ok {
+			ls.LastVisitScore = score
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Darts thrown at a finish and the ones that hit it
+	rows, err = s.read.Query(`
+		SELECT user_id,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN valid = 1 AND score_after = 0 THEN 1 ELSE 0 END), 0)
+		FROM (
+			SELECT t.user_id, t.valid, t.score_after, `+scoreBeforeExpr+` AS score_before
+			FROM throws t
+			JOIN games g ON t.game_id = g.id
+			WHERE t.game_id = ?
+		) darts
+		WHERE `+checkoutAttemptCondition+`
+		GROUP BY user_id`, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, attempts, hits int
+		if err := rows.Scan(&userID, &attempts, &hits); err != nil {
+			return nil, err
+		}
+		if ls, ok := stats[userID]; ok {
+			ls.CheckoutAttempts = attempts
+			ls.CheckoutHits = hits
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	live := make([]LivePlayerStats, 0, len(g.Players))
+	for _, p := range g.Players {
+		live = append(live, *stats[p.UserID])
+	}
+	return live, nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"math"
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// gameStatistics loads a game and returns its statistics
+func gameStatistics(t *testing.T, s *SQLStore, gameID int) (*GameStatistics, error) {
+	t.Helper()
+	g, err := s.GetGame(gameID)
+	if err != nil || g == nil {
+		t.Fatalf("Failed to load game: %+v, %v", g, err)
+	}
+	return s.GetGameStatistics(g)
+}
+
+func TestGetGameStatistics_Live(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	darts := bestOfThreeDarts(alice, bob)
+
+	// Stop during Alice's first visit of set 2
+	playDarts(t, s, g.ID, darts[:11])
+
+	stats, err := gameStatistics(t, s, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get game statistics: %v", err)
+	}
+	if stats.Status == models.GameStatusFinished {
+		t.Errorf("Expected unfinished game, got %s", stats.Status)
+	}
+	if stats.CurrentLeg == nil {
+		t.Fatal("Expected current leg for an active game")
+	}
+	if stats.CurrentLeg.SetNo != 2 || len(stats.CurrentLeg.Visits) != 2 {
+		t.Fatalf("Expected 2 visits in set 2, got set %d with %d visits", stats.CurrentLeg.SetNo, len(stats.CurrentLeg.Visits))
+	}
+	if v := stats.CurrentLeg.Visits[1]; v.UserID != alice || v.Darts != 1 || v.ScoreAfter != 41 {
+		t.Errorf("Expected Alice's visit in progress at 41 after 1 dart, got %+v", v)
+	}
+
+	if len(stats.Live) != 2 {
+		t.Fatalf("Expected live stats for 2 players, got %d", len(stats.Live))
+	}
+	a, b := stats.Live[0], stats.Live[1]
+	if a.UserID != alice || b.UserID != bob {
+		t.Fatalf("Expected live stats in player order, got %d and %d", a.UserID, b.UserID)
+	}
+	// Alice: 161 points in 5 darts, 1 dart this leg
+	if math.Abs(a.Average3Dart-96.6) > 0.01 {
+		t.Errorf("Expected Alice's average 96.6, got %f", a.Average3Dart)
+	}
+	if a.LegDarts != 1 || a.LastVisitScore != 60 {
+		t.Errorf("Expected Alice 1 leg dart and last visit 60, got %d and %d", a.LegDarts, a.LastVisitScore)
+	}
+	// Alice missed once at 40 before hitting D20
+	if a.CheckoutAttempts != 2 || a.CheckoutHits != 1 {
+		t.Errorf("Expected Alice 1/2 checkouts, got %d/%d", a.CheckoutHits, a.CheckoutAttempts)
+	}
+	// Bob: 121 points in 6 darts, missed once at 40
+	if math.Abs(b.Average3Dart-60.5) > 0.01 {
+		t.Errorf("Expected Bob's average 60.5, got %f", b.Average3Dart)
+	}
+	if b.LegDarts != 3 || b.LastVisitScore != 61 {
+		t.Errorf("Expected Bob 3 leg darts and last visit 61, got %d and %d", b.LegDarts, b.LastVisitScore)
+	}
+	if b.CheckoutAttempts != 1 || b.CheckoutHits != 0 {
+		t.Errorf("Expected Bob 0/1 checkouts, got %d/%d", b.CheckoutHits, b.CheckoutAttempts)
+	}
+
+	// Alice busts, Bob checks out and set 3 has not been started
+	playDarts(t, s, g.ID, darts[11:])
+
+	stats, err = gameStatistics(t, s, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get game statistics: %v", err)
+	}
+	if stats.CurrentLeg == nil || stats.CurrentLeg.SetNo != 3 || len(stats.CurrentLeg.Visits) != 0 {
+		t.Errorf("Expected empty current leg in set 3, got %+v", stats.CurrentLeg)
+	}
+	a, b = stats.Live[0], stats.Live[1]
+	if a.LastVisitScore != 0 || a.LegDarts != 0 {
+		t.Errorf("Expected Alice's bust to score 0 and no leg darts, got %d and %d", a.LastVisitScore, a.LegDarts)
+	}
+	if b.CheckoutAttempts != 2 || b.CheckoutHits != 1 || b.LastVisitScore != 40 {
+		t.Errorf("Expected Bob 1/2 checkouts and last visit 40, got %d/%d and %d", b.CheckoutHits, b.CheckoutAttempts, b.LastVisitScore)
+	}
+
+	// A finished game has no current leg
+	playDarts(t, s, g.ID, []testDart{{alice, 20, 3}, {alice, 1, 1}, {alice, 20, 2}})
+
+	stats, err = gameStatistics(t, s, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get game statistics: %v", err)
+	}
+	if stats.Status != models.GameStatusFinished || stats.CurrentLeg != nil {
+		t.Errorf("Expected finished game without current leg, got %s and %+v", stats.Status, stats.CurrentLeg)
+	}
+	if a := stats.Live[0]; a.LegDarts != 3 {
+		t.Errorf("Expected Alice 3 darts in the final leg, got %d", a.LegDarts)
+	}
+}
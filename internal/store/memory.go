@@ -0,0 +1,22 @@
+package store
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// memoryStores numbers the in-memory databases, so every store gets its own
+var memoryStores atomic.Int64
+
+// NewMemoryStore opens an empty SQLite database that only lives in memory
+// and is gone once the store is closed. It is meant for tests and demo mode.
+func NewMemoryStore() (*SQLStore, error) {
+	// A shared cache lets all connections see the same database. It is
+	// dropped with its last connection, so there is no separate read pool
+	// and the single write connection is kept open for the store's lifetime.
+	dsn := fmt.Sprintf("file:darts-memory-%d?mode=memory&cache=shared", memoryStores.Add(1))
+	opts := DefaultSQLiteOptions()
+	opts.JournalMode = "MEMORY"
+	opts.ReadConnections = 0
+	return NewStoreWithOptions(dsn, opts)
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// ErrMergeSameUser is returned when a player would be merged into themselves
+var ErrMergeSameUser = errors.New("cannot merge a player into themselves")
+
+// MergeOptions controls MergeUsers
+type MergeOptions struct {
+	// DryRun reports what would change without changing anything
+	DryRun bool
+	// DeleteSharedGames deletes the games both players played in. By default
+	// they stay with the merged player, since a player cannot play
+	// themselves.
+	DeleteSharedGames bool
+}
+
+// MergeReport lists the rows a merge moved, or would move in a dry run
+type MergeReport struct {
+	DryRun             bool  `json:"dry_run"`
+	Games              int   `json:"games"`
+	Throws             int   `json:"throws"`
+	Visits             int   `json:"visits"`
+	GamesWon           int   `json:"games_won"`
+	LegsWon            int   `json:"legs_won"`
+	RatingHistory      int   `json:"rating_history"`
+	Achievements       int   `json:"achievements"`
+	SharedGames        []int `json:"shared_games"`
+	SharedGamesDeleted bool  `json:"shared_games_deleted"`
+	// DuplicateAchievements are one-off achievements both players had; the
+	// later one is removed
+	DuplicateAchievements int `json:"duplicate_achievements"`
+}
+
+// MergeUsers moves the history of player from to player into, for a person
+// who ended up with two accounts, and archives from. Ratings and statistics
+// are recalculated. Neither player may be in an unfinished game.
+func (s *SQLStore) MergeUsers(into, from int, opts MergeOptions) (*MergeReport, error) {
+	if into == from {
+		return nil, ErrMergeSameUser
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, id := range []int{into, from} {
+		if err := checkUserDeletable(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &MergeReport{DryRun: opts.DryRun, SharedGames: []int{}}
+	rows, err := tx.Query(`
+		SELECT game_id FROM game_players
+		WHERE user_id = ? AND game_id IN (SELECT game_id FROM game_players WHERE user_id = ?)
+		ORDER BY game_id`, from, into)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.SharedGames = append(report.SharedGames, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.DeleteSharedGames {
+		for _, gameID := range report.SharedGames {
+			if err := deleteGame(tx, gameID); err != nil {
+				return nil, err
+			}
+		}
+		report.SharedGamesDeleted = len(report.SharedGames) > 0
+	}
+
+	// Games of into are excluded, so the shared games keep both players
+	const notShared = `game_id NOT IN (SELECT game_id FROM game_players WHERE user_id = ?)`
+
+	// Achievements unlocked once per player, or once per win streak length,
+	// are kept once, the earlier one. The duplicates go before the move, as
+	// the unique indexes allow none.
+	err = tx.QueryRow(`SELECT COUNT(*) FROM achievements WHERE user_id = ? AND `+notShared, from, into).Scan(&report.Achievements)
+	if err != nil {
+		return nil, err
+	}
+	// Those of into and those of from that are about to move
+	const merged = `(user_id = ? OR (user_id = ? AND ` + notShared + `))`
+	result, err := tx.Exec(`
+		DELETE FROM achievements
+		WHERE `+merged+` AND type IN (?, ?, ?) AND EXISTS (
+			SELECT 1 FROM achievements earlier
+			WHERE `+merged+` AND earlier.type = achievements.type
+				AND (earlier.type <> ? OR earlier.value = achievements.value)
+				AND (earlier.created_at < achievements.created_at
+					OR (earlier.created_at = achievements.created_at AND earlier.id < achievements.id))
+		)`,
+		into, from, into, models.AchievementFirst180, models.AchievementTonPlusFinish, models.AchievementWinStreak,
+		into, from, into, models.AchievementWinStreak)
+	if err != nil {
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	report.DuplicateAchievements = int(n)
+
+	// game_players goes last, since the other statements select by it
+	moves := []struct {
+		count *int
+		query string
+	}{
+		{&report.Throws, `UPDATE throws SET user_id = ? WHERE user_id = ? AND ` + notShared},
+		{&report.Visits, `UPDATE visits SET user_id = ? WHERE user_id = ? AND ` + notShared},
+		{&report.LegsWon, `UPDATE legs SET winner_id = ? WHERE winner_id = ? AND ` + notShared},
+		{&report.GamesWon, `UPDATE games SET winner_id = ? WHERE winner_id = ? AND id NOT IN (SELECT game_id FROM game_players WHERE user_id = ?)`},
+		{&report.RatingHistory, `UPDATE rating_history SET user_id = ? WHERE user_id = ? AND ` + notShared},
+		{nil, `UPDATE achievements SET user_id = ? WHERE user_id = ? AND ` + notShared},
+		{&report.Games, `UPDATE game_players SET user_id = ? WHERE user_id = ? AND ` + notShared},
+	}
+	for _, m := range moves {
+		result, err := tx.Exec(m.query, into, from, into)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if m.count != nil {
+			*m.count = int(n)
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP) WHERE id = ?`, from); err != nil {
+		return nil, err
+	}
+	if _, err := recomputeRatings(tx); err != nil {
+		return nil, err
+	}
+	if _, err := rebuildStatsAggregates(tx); err != nil {
+		return nil, err
+	}
+
+	return report, tx.Commit()
+}
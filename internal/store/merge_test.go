@@ -0,0 +1,134 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+func TestMergeUsers(t *testing.T) {
+	s, _, alice, bob := setupFinishedGame(t)
+	defer s.Close()
+
+	// A second account of Alice, with a win against Bob
+	ally, err := s.CreateUser("Ally")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	g, err := s.CreateGame(101, 1, true, []int{ally.ID, bob})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	playDarts(t, s, g.ID, bestOfThreeDarts(ally.ID, bob)[:7])
+	if _, err := s.RecomputeRatings(); err != nil {
+		t.Fatalf("Failed to compute ratings: %v", err)
+	}
+	// Both accounts have thrown their first 180
+	for _, id := range []int{alice, ally.ID} {
+		_, err := s.db.Exec(`
+			INSERT INTO achievements (user_id, type, value, game_id, throw_id)
+			SELECT user_id, ?, 180, game_id, MIN(id) FROM throws WHERE user_id = ? GROUP BY user_id, game_id`,
+			models.AchievementFirst180, id)
+		if err != nil {
+			t.Fatalf("Failed to insert achievement: %v", err)
+		}
+	}
+
+	if _, err := s.MergeUsers(alice, alice, MergeOptions{}); err != ErrMergeSameUser {
+		t.Errorf("Expected ErrMergeSameUser, got %v", err)
+	}
+
+	report, err := s.MergeUsers(alice, ally.ID, MergeOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to merge users: %v", err)
+	}
+	if !report.DryRun || report.Games != 1 || report.Throws != 4 || report.GamesWon != 1 ||
+		report.LegsWon != 1 || report.RatingHistory != 1 || report.Achievements != 1 ||
+		report.DuplicateAchievements != 1 || len(report.SharedGames) != 0 {
+		t.Errorf("Expected one game with 4 throws to move, got %+v", report)
+	}
+	if u, _ := s.GetUser(ally.ID); u.ArchivedAt != nil {
+		t.Error("Expected a dry run to leave the player active")
+	}
+	if stats, _ := s.GetUserStats(alice); stats.TotalGames != 1 {
+		t.Errorf("Expected a dry run to leave the games, got %d", stats.TotalGames)
+	}
+
+	report, err = s.MergeUsers(alice, ally.ID, MergeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to merge users: %v", err)
+	}
+	if report.DryRun || report.Games != 1 {
+		t.Errorf("Expected one game moved, got %+v", report)
+	}
+
+	if u, _ := s.GetUser(ally.ID); u.ArchivedAt == nil {
+		t.Error("Expected the merged player to be archived")
+	}
+	stats, err := s.GetUserStats(alice)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalGames != 2 || stats.Wins != 2 || stats.TotalThrows != 8 {
+		t.Errorf("Expected 2 games won with 8 throws, got %+v", stats)
+	}
+	history, err := s.GetRatingHistory(alice)
+	if err != nil {
+		t.Fatalf("Failed to get rating history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected 2 rated games, got %d", len(history))
+	}
+	if history, _ := s.GetRatingHistory(ally.ID); len(history) != 0 {
+		t.Errorf("Expected no ratings left for the merged player, got %d", len(history))
+	}
+	achievements, err := s.GetAchievements(alice)
+	if err != nil {
+		t.Fatalf("Failed to get achievements: %v", err)
+	}
+	if len(achievements) != 1 || achievements[0].GameID == g.ID {
+		t.Errorf("Expected only the earlier first 180, got %+v", achievements)
+	}
+}
+
+func TestMergeUsers_SharedGames(t *testing.T) {
+	s, g, alice, bob := setupFinishedGame(t)
+	defer s.Close()
+
+	report, err := s.MergeUsers(alice, bob, MergeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to merge users: %v", err)
+	}
+	if len(report.SharedGames) != 1 || report.SharedGames[0] != g.ID || report.Games != 0 || report.SharedGamesDeleted {
+		t.Errorf("Expected the shared game to stay, got %+v", report)
+	}
+	if stats, _ := s.GetUserStats(bob); stats.TotalGames != 1 {
+		t.Errorf("Expected the merged player to keep the shared game, got %d", stats.TotalGames)
+	}
+
+	if err := s.UnarchiveUser(bob); err != nil {
+		t.Fatalf("Failed to unarchive user: %v", err)
+	}
+	report, err = s.MergeUsers(alice, bob, MergeOptions{DeleteSharedGames: true})
+	if err != nil {
+		t.Fatalf("Failed to merge users: %v", err)
+	}
+	if !report.SharedGamesDeleted {
+		t.Errorf("Expected the shared game to be deleted, got %+v", report)
+	}
+	if game, _ := s.GetGame(g.ID); game != nil {
+		t.Error("Expected the shared game to be gone")
+	}
+	if stats, _ := s.GetUserStats(alice); stats.TotalGames != 0 {
+		t.Errorf("Expected no games left, got %d", stats.TotalGames)
+	}
+}
+
+func TestMergeUsers_InActiveGame(t *testing.T) {
+	s, _, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	if _, err := s.MergeUsers(alice, bob, MergeOptions{DryRun: true}); err != ErrUserInActiveGame {
+		t.Errorf("Expected ErrUserInActiveGame, got %v", err)
+	}
+}
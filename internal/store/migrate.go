@@ -0,0 +1,348 @@
+package store
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// release than this server
+var ErrSchemaTooNew = errors.New("database schema is newer than this server")
+
+// migrationFiles holds the schema of each dialect: base.sql with the tables
+// of the first release, and numbered NNNN_name.up.sql / .down.sql pairs
+// applied in order on top of it
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version  int
+	name     string
+	up       string
+	down     string
+	checksum string // SHA-256 of the up file
+}
+
+// migrationHooks convert existing data after the SQL of a migration has run
+var migrationHooks = map[int]func(*txn) error{
+	2: backfillLegsAndVisits,
+	6: func(tx *txn) error {
+		_, err := rebuildStatsAggregates(tx)
+		return err
+	},
+	9: normaliseUserNames,
+}
+
+var dialectMigrations = map[dialect][]migration{
+	dialectSQLite:   mustLoadMigrations(dialectSQLite),
+	dialectPostgres: mustLoadMigrations(dialectPostgres),
+}
+
+// mustLoadMigrations reads the embedded migrations of a dialect. The files
+// are part of the binary, so a malformed set is a programming error.
+func mustLoadMigrations(d dialect) []migration {
+	dir := path.Join("migrations", d.String())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		panic(err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, e := range entries {
+		if e.Name() == "base.sql" {
+			continue
+		}
+		match := migrationFileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			panic(fmt.Sprintf("migrations: unexpected file %s/%s", dir, e.Name()))
+		}
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		if m.name != match[2] {
+			panic(fmt.Sprintf("migrations: version %d in %s has two names", version, dir))
+		}
+		if match[3] == "up" {
+			sum := sha256.Sum256(data)
+			m.up = string(data)
+			m.checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i, m := range migrations {
+		if m.version != i+1 {
+			panic(fmt.Sprintf("migrations: %s is missing version %d", dir, i+1))
+		}
+		if m.up == "" || m.down == "" {
+			panic(fmt.Sprintf("migrations: version %d in %s needs an up and a down file", m.version, dir))
+		}
+	}
+	return migrations
+}
+
+// latestVersion returns the newest schema version this server knows
+func latestVersion() int {
+	migrations := dialectMigrations[dialectSQLite]
+	return migrations[len(migrations)-1].version
+}
+
+// MigrationState describes one schema version in the database or the binary
+type MigrationState struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set if the migration file changed after it was applied
+	Modified bool
+	// Unknown is set for applied versions from a newer release
+	Unknown bool
+}
+
+type appliedMigration struct {
+	appliedAt time.Time
+	checksum  string
+}
+
+// initSchemaVersion creates the table recording applied migrations. Rows
+// from releases before checksums were recorded get the current checksum.
+func (s *SQLStore) initSchemaVersion() error {
+	if s.db.dialect == dialectPostgres {
+		_, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_version (
+				version INTEGER PRIMARY KEY,
+				applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			ALTER TABLE schema_version ADD COLUMN IF NOT EXISTS checksum TEXT;
+		`)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_version (
+				version INTEGER PRIMARY KEY,
+				applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+		var hasChecksum bool
+		err = s.db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('schema_version') WHERE name = 'checksum'`).Scan(&hasChecksum)
+		if err != nil {
+			return err
+		}
+		if !hasChecksum {
+			if _, err := s.db.Exec(`ALTER TABLE schema_version ADD COLUMN checksum TEXT`); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, m := range s.migrations() {
+		_, err := s.db.Exec(`UPDATE schema_version SET checksum = ? WHERE version = ? AND checksum IS NULL`, m.checksum, m.version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) migrations() []migration {
+	return dialectMigrations[s.db.dialect]
+}
+
+func (s *SQLStore) appliedMigrations() (map[int]appliedMigration, error) {
+	rows, err := s.db.Query(`SELECT version, applied_at, COALESCE(checksum, '') FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.appliedAt, &a.checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// checkSchemaVersion refuses databases migrated by a newer release
+func (s *SQLStore) checkSchemaVersion() error {
+	var version int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return err
+	}
+	if latest := latestVersion(); version > latest {
+		return fmt.Errorf("%w: version %d, supported %d", ErrSchemaTooNew, version, latest)
+	}
+	return nil
+}
+
+// MigrationStatus lists the known migrations, followed by applied versions
+// this server does not know
+func (s *SQLStore) MigrationStatus() ([]MigrationState, error) {
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	for _, m := range s.migrations() {
+		state := MigrationState{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			state.Applied = true
+			state.AppliedAt = a.appliedAt
+			state.Modified = a.checksum != m.checksum
+			delete(applied, m.version)
+		}
+		states = append(states, state)
+	}
+
+	var unknown []MigrationState
+	for version, a := range applied {
+		unknown = append(unknown, MigrationState{Version: version, Applied: true, AppliedAt: a.appliedAt, Unknown: true})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(states, unknown...), nil
+}
+
+// MigrateUp creates the base tables and applies all pending migrations, each
+// in its own transaction. It returns the number of migrations applied.
+func (s *SQLStore) MigrateUp() (int, error) {
+	if err := s.checkSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	base, err := fs.ReadFile(migrationFiles, path.Join("migrations", s.db.dialect.String(), "base.sql"))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.db.Exec(string(base)); err != nil {
+		log.Printf("Error initializing schema: %v", err)
+		return 0, err
+	}
+
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, m := range s.migrations() {
+		if a, ok := applied[m.version]; ok {
+			if a.checksum != m.checksum {
+				log.Printf("Warning: migration %d (%s) was changed after it was applied", m.version, m.name)
+			}
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return n, fmt.Errorf("migration %d failed: %w", m.version, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *SQLStore) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.up); err != nil {
+		return err
+	}
+	if hook := migrationHooks[m.version]; hook != nil {
+		if err := hook(tx); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_version (version, checksum) VALUES (?, ?)`, m.version, m.checksum)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MigrateDown reverts applied migrations newer than version, newest first,
+// and returns the number reverted. Version 0 leaves only the base tables.
+func (s *SQLStore) MigrateDown(version int) (int, error) {
+	if version < 0 {
+		return 0, fmt.Errorf("invalid version %d", version)
+	}
+	if err := s.checkSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	migrations := s.migrations()
+	n := 0
+	for i := len(migrations) - 1; i >= 0 && migrations[i].version > version; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		if err := s.revertMigration(m); err != nil {
+			return n, fmt.Errorf("reverting migration %d failed: %w", m.version, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *SQLStore) revertMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.down); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_version WHERE version = ?`, m.version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations_DialectsMatch(t *testing.T) {
+	sqlite := dialectMigrations[dialectSQLite]
+	postgres := dialectMigrations[dialectPostgres]
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("Expected the same number of migrations, got %d SQLite and %d PostgreSQL", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].version != postgres[i].version || sqlite[i].name != postgres[i].name {
+			t.Errorf("Expected migration %d_%s on both databases, got %d_%s on PostgreSQL",
+				sqlite[i].version, sqlite[i].name, postgres[i].version, postgres[i].name)
+		}
+	}
+}
+
+func TestMigrateDown_RoundTrip(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	playDarts(t, s, g.ID, bestOfThreeDarts(alice, bob)[:11])
+
+	n, err := s.MigrateDown(0)
+	if err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	if n != latestVersion() {
+		t.Errorf("Expected %d migrations reverted, got %d", latestVersion(), n)
+	}
+
+	states, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	for _, m := range states {
+		if m.Applied {
+			t.Errorf("Expected migration %d to be reverted", m.Version)
+		}
+	}
+	if _, err := s.db.Exec(`SELECT 1 FROM legs`); err == nil {
+		t.Error("Expected the legs table to be dropped")
+	}
+
+	n, err = s.MigrateUp()
+	if err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	if n != latestVersion() {
+		t.Errorf("Expected %d migrations applied, got %d", latestVersion(), n)
+	}
+
+	// The throws survive, and the hooks restore their legs
+	legs, err := s.GetLegs(g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get legs: %v", err)
+	}
+	if len(legs) != 2 {
+		t.Errorf("Expected 2 legs after migrating up again, got %d", len(legs))
+	}
+}
+
+func TestMigrationStatus_Modified(t *testing.T) {
+	skipOnPostgres(t)
+	s, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.db.Exec(`UPDATE schema_version SET checksum = 'edited' WHERE version = 2`); err != nil {
+		t.Fatalf("Failed to change checksum: %v", err)
+	}
+
+	states, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	if len(states) != latestVersion() {
+		t.Fatalf("Expected %d migrations, got %d", latestVersion(), len(states))
+	}
+	for _, m := range states {
+		if !m.Applied {
+			t.Errorf("Expected migration %d to be applied", m.Version)
+		}
+		if m.Modified != (m.Version == 2) {
+			t.Errorf("Expected only migration 2 to be modified, got %+v", m)
+		}
+	}
+}
+
+func TestNewStore_RefusesNewerSchema(t *testing.T) {
+	skipOnPostgres(t)
+	dbPath := filepath.Join(t.TempDir(), "darts.db")
+	s, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO schema_version (version) VALUES (?)`, latestVersion()+1); err != nil {
+		t.Fatalf("Failed to record version: %v", err)
+	}
+	s.Close()
+
+	if _, err := NewStore(dbPath); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+
+	s, err = OpenSQLite(dbPath, DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.MigrateDown(0); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew migrating down, got %v", err)
+	}
+	states, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	if last := states[len(states)-1]; !last.Unknown || last.Version != latestVersion()+1 {
+		t.Errorf("Expected the newer version listed as unknown, got %+v", last)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE throws DROP COLUMN valid;
@@ -0,0 +1 @@
+ALTER TABLE throws ADD COLUMN valid INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+DROP TABLE visits;
+DROP TABLE legs;
+
+DROP INDEX idx_throws_position;
+ALTER TABLE games DROP COLUMN current_visit_number;
+ALTER TABLE throws DROP COLUMN dart_no;
+ALTER TABLE throws DROP COLUMN visit_no;
+ALTER TABLE throws DROP COLUMN leg_no;
+ALTER TABLE throws DROP COLUMN set_no;
@@ -0,0 +1,42 @@
+-- Explicit set/leg/visit structure. Existing throws are given their
+-- position by backfillLegsAndVisits after this file has run.
+
+ALTER TABLE throws ADD COLUMN set_no INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE throws ADD COLUMN leg_no INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE throws ADD COLUMN visit_no INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE throws ADD COLUMN dart_no INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE games ADD COLUMN current_visit_number INTEGER DEFAULT 0;
+
+CREATE TABLE legs (
+	id SERIAL PRIMARY KEY,
+	game_id INTEGER NOT NULL,
+	set_no INTEGER NOT NULL,
+	leg_no INTEGER NOT NULL,
+	winner_id INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	finished_at TIMESTAMP,
+	UNIQUE (game_id, set_no, leg_no),
+	FOREIGN KEY (game_id) REFERENCES games(id)
+);
+
+CREATE TABLE visits (
+	id SERIAL PRIMARY KEY,
+	leg_id INTEGER NOT NULL,
+	game_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	visit_no INTEGER NOT NULL,
+	score_before INTEGER NOT NULL,
+	score_after INTEGER NOT NULL,
+	darts INTEGER NOT NULL DEFAULT 0,
+	bust INTEGER NOT NULL DEFAULT 0,
+	checkout INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (leg_id, visit_no),
+	FOREIGN KEY (leg_id) REFERENCES legs(id),
+	FOREIGN KEY (game_id) REFERENCES games(id)
+);
+
+CREATE INDEX idx_throws_position ON throws(game_id, set_no, leg_no, visit_no, dart_no);
+CREATE INDEX idx_legs_winner ON legs(winner_id);
+CREATE INDEX idx_visits_game ON visits(game_id);
+CREATE INDEX idx_visits_user ON visits(user_id);
@@ -0,0 +1 @@
+DROP INDEX idx_throws_user_created;
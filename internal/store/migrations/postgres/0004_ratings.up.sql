@@ -0,0 +1,20 @@
+CREATE TABLE player_ratings (
+	user_id INTEGER PRIMARY KEY,
+	rating DOUBLE PRECISION NOT NULL,
+	games_rated INTEGER NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE rating_history (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	game_id INTEGER NOT NULL,
+	rating_before DOUBLE PRECISION NOT NULL,
+	rating_after DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (user_id, game_id),
+	FOREIGN KEY (game_id) REFERENCES games(id)
+);
+
+CREATE INDEX idx_rating_history_game ON rating_history(game_id);
+CREATE INDEX idx_player_ratings_rating ON player_ratings(rating);
@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN pref_double_out;
+ALTER TABLE users DROP COLUMN pref_best_of;
+ALTER TABLE users DROP COLUMN pref_total_points;
+ALTER TABLE users DROP COLUMN walk_on_colour;
+ALTER TABLE users DROP COLUMN avatar;
+ALTER TABLE users DROP COLUMN nickname;
@@ -0,0 +1,9 @@
+-- Profile of a player. avatar is the file name of the picture in the
+-- avatar directory; the preferences are the settings of a new game, where
+-- 0 and NULL mean no preference.
+ALTER TABLE users ADD COLUMN nickname TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN walk_on_colour TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN pref_total_points INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN pref_best_of INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN pref_double_out INTEGER;
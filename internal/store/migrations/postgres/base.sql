@@ -0,0 +1,51 @@
+-- Tables of the first release, before schema versions were recorded.
+-- Databases created since then get them here as well. Users can be
+-- deleted while their games stay, as on SQLite where foreign keys are not
+-- enforced, so user references have no constraint.
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS games (
+	id SERIAL PRIMARY KEY,
+	status TEXT NOT NULL,
+	total_points INTEGER NOT NULL,
+	best_of_sets INTEGER NOT NULL,
+	double_out INTEGER DEFAULT 0,
+	winner_id INTEGER,
+	current_player_index INTEGER DEFAULT 0,
+	current_throw_number INTEGER DEFAULT 0,
+	current_turn_points INTEGER DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS game_players (
+	game_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	player_order INTEGER NOT NULL,
+	sets_won INTEGER DEFAULT 0,
+	current_points INTEGER,
+	PRIMARY KEY (game_id, user_id),
+	FOREIGN KEY (game_id) REFERENCES games(id)
+);
+
+CREATE TABLE IF NOT EXISTS throws (
+	id SERIAL PRIMARY KEY,
+	game_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	points INTEGER NOT NULL,
+	multiplier INTEGER NOT NULL,
+	score_after INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (game_id) REFERENCES games(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_games_status ON games(status);
+CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner_id);
+CREATE INDEX IF NOT EXISTS idx_game_players_user ON game_players(user_id);
+CREATE INDEX IF NOT EXISTS idx_throws_game ON throws(game_id);
+CREATE INDEX IF NOT EXISTS idx_throws_user ON throws(user_id);
+CREATE INDEX IF NOT EXISTS idx_throws_created ON throws(created_at);
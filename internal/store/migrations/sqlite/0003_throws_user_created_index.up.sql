@@ -0,0 +1,2 @@
+-- Per-player queries filtered by date (heatmap, trends)
+CREATE INDEX idx_throws_user_created ON throws(user_id, created_at);
@@ -0,0 +1,22 @@
+CREATE TABLE player_ratings (
+	user_id INTEGER PRIMARY KEY,
+	rating REAL NOT NULL,
+	games_rated INTEGER NOT NULL DEFAULT 0,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE rating_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	game_id INTEGER NOT NULL,
+	rating_before REAL NOT NULL,
+	rating_after REAL NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (user_id, game_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (game_id) REFERENCES games(id)
+);
+
+CREATE INDEX idx_rating_history_game ON rating_history(game_id);
+CREATE INDEX idx_player_ratings_rating ON player_ratings(rating);
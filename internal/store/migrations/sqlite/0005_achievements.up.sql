@@ -0,0 +1,14 @@
+CREATE TABLE achievements (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	value INTEGER NOT NULL DEFAULT 0,
+	game_id INTEGER NOT NULL,
+	throw_id INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (game_id) REFERENCES games(id),
+	FOREIGN KEY (throw_id) REFERENCES throws(id)
+);
+
+CREATE INDEX idx_achievements_user ON achievements(user_id, type);
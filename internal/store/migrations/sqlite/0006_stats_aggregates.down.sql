@@ -0,0 +1,2 @@
+DROP TABLE user_stats;
+DROP TABLE game_player_set_stats;
@@ -0,0 +1,36 @@
+-- Aggregates behind the player statistics. They are filled from the
+-- existing throws by rebuildStatsAggregates after this file has run.
+
+CREATE TABLE game_player_set_stats (
+	game_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	set_no INTEGER NOT NULL,
+	throws INTEGER NOT NULL DEFAULT 0,
+	points INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (game_id, set_no, user_id),
+	FOREIGN KEY (game_id) REFERENCES games(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE user_stats (
+	user_id INTEGER PRIMARY KEY,
+	games INTEGER NOT NULL DEFAULT 0,
+	wins INTEGER NOT NULL DEFAULT 0,
+	throws INTEGER NOT NULL DEFAULT 0,
+	points INTEGER NOT NULL DEFAULT 0,
+	first9_throws INTEGER NOT NULL DEFAULT 0,
+	first9_points INTEGER NOT NULL DEFAULT 0,
+	checkout_attempts INTEGER NOT NULL DEFAULT 0,
+	checkout_hits INTEGER NOT NULL DEFAULT 0,
+	highest_checkout INTEGER NOT NULL DEFAULT 0,
+	tons_100 INTEGER NOT NULL DEFAULT 0,
+	tons_140 INTEGER NOT NULL DEFAULT 0,
+	maximums_180 INTEGER NOT NULL DEFAULT 0,
+	busts INTEGER NOT NULL DEFAULT 0,
+	legs_played INTEGER NOT NULL DEFAULT 0,
+	legs_won INTEGER NOT NULL DEFAULT 0,
+	best_leg_darts INTEGER NOT NULL DEFAULT 0,
+	worst_leg_darts INTEGER NOT NULL DEFAULT 0,
+	won_leg_darts INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,2 @@
+-- Archived players are hidden from the player list but keep their history
+ALTER TABLE users ADD COLUMN archived_at DATETIME;
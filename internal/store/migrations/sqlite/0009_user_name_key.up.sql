@@ -0,0 +1,5 @@
+-- Case-insensitive key of the normalised name (see nameKey), so "alice" and
+-- "Alice " cannot both exist. The migration hook normalises the existing
+-- names and fills it in.
+ALTER TABLE users ADD COLUMN name_key TEXT;
+CREATE UNIQUE INDEX idx_users_name_key ON users(name_key);
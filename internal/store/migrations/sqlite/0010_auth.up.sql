@@ -0,0 +1,29 @@
+-- Logins of the web UI, separate from the players in users. role is admin
+-- or scorer.
+CREATE TABLE accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	role TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Sessions and API tokens are stored as SHA-256 hashes of the token only
+CREATE TABLE sessions (
+	token_hash TEXT PRIMARY KEY,
+	account_id INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+CREATE TABLE api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	role TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_used_at DATETIME
+);
+
+CREATE INDEX idx_sessions_account ON sessions(account_id);
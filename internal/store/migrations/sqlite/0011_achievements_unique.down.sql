@@ -0,0 +1,2 @@
+DROP INDEX idx_achievements_win_streak;
+DROP INDEX idx_achievements_once;
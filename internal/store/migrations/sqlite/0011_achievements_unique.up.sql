@@ -0,0 +1,15 @@
+-- One-off achievements are unlocked once per player, each win streak
+-- milestone once per player. Duplicates unlocked by concurrent throws
+-- before these indexes existed are removed, keeping the first.
+DELETE FROM achievements
+WHERE type IN ('first_180', 'ton_plus_finish', 'win_streak')
+	AND id NOT IN (
+		SELECT MIN(id) FROM achievements
+		WHERE type IN ('first_180', 'ton_plus_finish', 'win_streak')
+		GROUP BY user_id, type, CASE WHEN type = 'win_streak' THEN value ELSE 0 END
+	);
+
+CREATE UNIQUE INDEX idx_achievements_once ON achievements(user_id, type)
+	WHERE type IN ('first_180', 'ton_plus_finish');
+CREATE UNIQUE INDEX idx_achievements_win_streak ON achievements(user_id, type, value)
+	WHERE type = 'win_streak';
@@ -0,0 +1,52 @@
+-- Tables of the first release, before schema versions were recorded.
+-- Databases created since then get them here as well.
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS games (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	status TEXT NOT NULL,
+	total_points INTEGER NOT NULL,
+	best_of_sets INTEGER NOT NULL,
+	double_out INTEGER DEFAULT 0,
+	winner_id INTEGER,
+	current_player_index INTEGER DEFAULT 0,
+	current_throw_number INTEGER DEFAULT 0,
+	current_turn_points INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (winner_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS game_players (
+	game_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	player_order INTEGER NOT NULL,
+	sets_won INTEGER DEFAULT 0,
+	current_points INTEGER,
+	PRIMARY KEY (game_id, user_id),
+	FOREIGN KEY (game_id) REFERENCES games(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS throws (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	game_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	points INTEGER NOT NULL,
+	multiplier INTEGER NOT NULL,
+	score_after INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (game_id) REFERENCES games(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_games_status ON games(status);
+CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner_id);
+CREATE INDEX IF NOT EXISTS idx_game_players_user ON game_players(user_id);
+CREATE INDEX IF NOT EXISTS idx_throws_game ON throws(game_id);
+CREATE INDEX IF NOT EXISTS idx_throws_user ON throws(user_id);
+CREATE INDEX IF NOT EXISTS idx_throws_created ON throws(created_at);
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormaliseName returns a player name as it is stored: in Unicode NFC, so
+// an "é" typed on different keyboards is the same letter, without leading
+// or trailing spaces and with inner runs of white space collapsed into one
+// space
+func NormaliseName(name string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(name)), " ")
+}
+
+// nameKey returns the case-insensitive key of a name, which is unique among
+// the players
+func nameKey(name string) string {
+	return cases.Fold().String(NormaliseName(name))
+}
+
+// normaliseUserNames normalises the names of existing players and fills in
+// their keys. Players whose name only differs from an earlier player's in
+// case or spacing get their ID appended, and can then be merged.
+func normaliseUserNames(tx *txn) error {
+	rows, err := tx.Query(`SELECT id, name FROM users ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type user struct {
+		id   int
+		name string
+	}
+	var normalised, other []user
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.id, &u.name); err != nil {
+			rows.Close()
+			return err
+		}
+		// Names that are already normalised keep them, so renaming the others
+		// cannot collide with a name not updated yet
+		if NormaliseName(u.name) == u.name {
+			normalised = append(normalised, u)
+		} else {
+			other = append(other, u)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for _, u := range append(normalised, other...) {
+		name := NormaliseName(u.name)
+		if keys[nameKey(name)] {
+			name = fmt.Sprintf("%s (%d)", name, u.id)
+			log.Printf("Renaming user %d from %q to %q, the name is taken", u.id, u.name, name)
+		}
+		keys[nameKey(name)] = true
+		if _, err := tx.Exec(`UPDATE users SET name = ?, name_key = ? WHERE id = ?`, name, nameKey(name), u.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
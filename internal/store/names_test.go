@@ -0,0 +1,124 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestNormaliseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Alice", "Alice"},
+		{"  Alice  ", "Alice"},
+		{"Mike \t  S", "Mike S"},
+		{"Jose\u0301", "Jos\u00e9"}, // Combining accent composed
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormaliseName(tt.name); got != tt.want {
+			t.Errorf("NormaliseName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if nameKey("STRASSE ") != nameKey("straße") {
+		t.Error("Expected names differing in case to share a key")
+	}
+}
+
+func TestCreateUser_CaseInsensitive(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, err := s.CreateUser("  Alice  ")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if alice.Name != "Alice" {
+		t.Errorf("Expected the name to be trimmed, got %q", alice.Name)
+	}
+	if _, err := s.CreateUser("alice"); err != ErrDuplicateUsername {
+		t.Errorf("Expected ErrDuplicateUsername, got %v", err)
+	}
+
+	// A player may change the case of their own name
+	name := "ALICE"
+	if u, err := s.UpdateUser(alice.ID, UserUpdate{Name: &name}); err != nil || u.Name != "ALICE" {
+		t.Errorf("Expected the name to change case, got %+v, %v", u, err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO users (name, name_key) VALUES ('Alice', 'alice')`); err == nil {
+		t.Error("Expected the unique index to refuse a second key")
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	for _, name := range []string{"Alina", "alice", "Bob", "Ali_", "Alfred"} {
+		if _, err := s.CreateUser(name); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	users, total, err := s.SearchUsers(UserFilter{Query: "ALI", Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to search users: %v", err)
+	}
+	if total != 3 || len(users) != 2 || users[0].Name != "Ali_" || users[1].Name != "alice" {
+		t.Errorf("Expected Ali_ and alice of 3 matches, got %d: %+v", total, users)
+	}
+	users, _, err = s.SearchUsers(UserFilter{Query: "ali", Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("Failed to search users: %v", err)
+	}
+	if len(users) != 1 || users[0].Name != "Alina" {
+		t.Errorf("Expected Alina on the second page, got %+v", users)
+	}
+
+	// The LIKE wildcard is matched literally
+	if _, total, _ := s.SearchUsers(UserFilter{Query: "Ali_"}); total != 1 {
+		t.Errorf("Expected only Ali_ for an underscore, got %d", total)
+	}
+	if _, total, _ := s.SearchUsers(UserFilter{Archived: true}); total != 0 {
+		t.Errorf("Expected no archived players, got %d", total)
+	}
+}
+
+func TestMigrateUp_NormalisesNames(t *testing.T) {
+	skipOnPostgres(t)
+	s, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.MigrateDown(8); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	for _, name := range []string{"alice", " Alice", "Bob  Smith"} {
+		if _, err := s.db.Exec(`INSERT INTO users (name) VALUES (?)`, name); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+	if _, err := s.MigrateUp(); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	if len(names) != 3 || names[0] != "alice" || names[1] != "Alice (2)" || names[2] != "Bob Smith" {
+		t.Errorf("Expected alice, Alice (2) and Bob Smith, got %q", names)
+	}
+}
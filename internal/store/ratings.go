@@ -0,0 +1,220 @@
+package store
+
+import (
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+	"github.com/michaelschlottmann/darts-web/internal/rating"
+)
+
+// RatingEntry is a player's position on the rating leaderboard
+type RatingEntry struct {
+	Rank       int     `json:"rank"`
+	UserID     int     `json:"user_id"`
+	UserName   string  `json:"user_name"`
+	Rating     float64 `json:"rating"`
+	GamesRated int     `json:"games_rated"`
+}
+
+// RatingChange is the rating change of a player caused by one game
+type RatingChange struct {
+	GameID       int       `json:"game_id"`
+	RatingBefore float64   `json:"rating_before"`
+	RatingAfter  float64   `json:"rating_after"`
+	Change       float64   `json:"change"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ApplyGameRatings updates the ratings of all players of a finished game.
+// Games that were already rated are skipped.
+func (s *SQLStore) ApplyGameRatings(gameID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := applyGameRatings(tx, gameID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecomputeRatings discards all ratings and replays every finished game in
+// the order the games were finished
+func (s *SQLStore) RecomputeRatings() (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	games, err := recomputeRatings(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	return games, tx.Commit()
+}
+
+func recomputeRatings(tx *txn) (int, error) {
+	if _, err := tx.Exec(`DELETE FROM rating_history; DELETE FROM player_ratings;`); err != nil {
+		return 0, err
+	}
+
+	// The last throw of a game is the one that finished it. Imported games
+	// carry their original timestamps, so time decides before insert order.
+	rows, err := tx.Query(`
+		SELECT g.id
+		FROM games g
+		WHERE g.status = ?
+		ORDER BY (SELECT MAX(t.created_at) FROM throws t WHERE t.game_id = g.id),
+			(SELECT MAX(t.id) FROM throws t WHERE t.game_id = g.id), g.id`,
+		models.GameStatusFinished)
+	if err != nil {
+		return 0, err
+	}
+	var gameIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		gameIDs = append(gameIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range gameIDs {
+		if err := applyGameRatings(tx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(gameIDs), nil
+}
+
+func applyGameRatings(tx *txn, gameID int) error {
+	var rated bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM rating_history WHERE game_id = ?)`, gameID).Scan(&rated); err != nil {
+		return err
+	}
+	if rated {
+		return nil
+	}
+
+	var status string
+	var bestOf int
+	err := tx.QueryRow(`SELECT status, best_of_sets FROM games WHERE id = ?`, gameID).Scan(&status, &bestOf)
+	if err != nil {
+		return err
+	}
+	if status != string(models.GameStatusFinished) {
+		return nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT gp.user_id, gp.sets_won, COALESCE(pr.rating, ?)
+		FROM game_players gp
+		LEFT JOIN player_ratings pr ON pr.user_id = gp.user_id
+		WHERE gp.game_id = ?
+		ORDER BY gp.player_order`, rating.InitialRating, gameID)
+	if err != nil {
+		return err
+	}
+	var results []rating.Result
+	for rows.Next() {
+		var r rating.Result
+		if err := rows.Scan(&r.UserID, &r.Score, &r.Rating); err != nil {
+			rows.Close()
+			return err
+		}
+		results = append(results, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Solo practice games are not rated
+	if len(results) < 2 {
+		return nil
+	}
+
+	updated := rating.Update(results, bestOf)
+	for _, r := range results {
+		after := updated[r.UserID]
+		_, err := tx.Exec(`
+			INSERT INTO player_ratings (user_id, rating, games_rated, updated_at)
+			VALUES (?, ?, 1, CURRENT_TIMESTAMP)
+			ON CONFLICT(user_id) DO UPDATE SET
+				rating = excluded.rating,
+				games_rated = player_ratings.games_rated + 1,
+				updated_at = excluded.updated_at`,
+			r.UserID, after)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO rating_history (user_id, game_id, rating_before, rating_after) VALUES (?, ?, ?, ?)`,
+			r.UserID, gameID, r.Rating, after)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRatingLeaderboard returns all rated players that are not archived, best
+// first
+func (s *SQLStore) GetRatingLeaderboard() ([]RatingEntry, error) {
+	rows, err := s.read.Query(`
+		SELECT pr.user_id, u.name, pr.rating, pr.games_rated
+		FROM player_ratings pr
+		JOIN users u ON u.id = pr.user_id
+		WHERE u.archived_at IS NULL
+		ORDER BY pr.rating DESC, u.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []RatingEntry{}
+	for rows.Next() {
+		var e RatingEntry
+		if err := rows.Scan(&e.UserID, &e.UserName, &e.Rating, &e.GamesRated); err != nil {
+			return nil, err
+		}
+		e.Rank = len(entries) + 1
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetRatingHistory returns a player's rating changes, oldest first
+func (s *SQLStore) GetRatingHistory(userID int) ([]RatingChange, error) {
+	rows, err := s.read.Query(`
+		SELECT game_id, rating_before, rating_after, created_at
+		FROM rating_history
+		WHERE user_id = ?
+		ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []RatingChange{}
+	for rows.Next() {
+		var c RatingChange
+		if err := rows.Scan(&c.GameID, &c.RatingBefore, &c.RatingAfter, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.Change = c.RatingAfter - c.RatingBefore
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/rating"
+)
+
+func TestApplyGameRatings(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	// Unfinished games are not rated
+	playDarts(t, s, g.ID, bestOfThreeDarts(alice, bob)[:7])
+	if err := s.ApplyGameRatings(g.ID); err != nil {
+		t.Fatalf("Failed to apply ratings: %v", err)
+	}
+	entries, err := s.GetRatingLeaderboard()
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected no ratings for an unfinished game, got %+v", entries)
+	}
+
+	// Alice wins set 2 as well and with it the game
+	playDarts(t, s, g.ID, []testDart{
+		{bob, 0, 1}, {bob, 0, 1}, {bob, 0, 1},
+		{alice, 20, 3}, {alice, 1, 1}, {alice, 20, 2}, // 101 checkout
+	})
+
+	// The finishing throw rated the game, so applying again must not rate
+	// it twice
+	for i := 0; i < 2; i++ {
+		if err := s.ApplyGameRatings(g.ID); err != nil {
+			t.Fatalf("Failed to apply ratings: %v", err)
+		}
+	}
+
+	entries, err = s.GetRatingLeaderboard()
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 2 || entries[0].UserID != alice || entries[0].Rank != 1 || entries[0].GamesRated != 1 {
+		t.Fatalf("Expected Alice on top after one rated game, got %+v", entries)
+	}
+	wantGain := rating.BaseK * rating.FormatWeight(3) / 2
+	if entries[0].Rating != rating.InitialRating+wantGain {
+		t.Errorf("Expected Alice at %.1f, got %.1f", rating.InitialRating+wantGain, entries[0].Rating)
+	}
+
+	history, err := s.GetRatingHistory(bob)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 1 || history[0].GameID != g.ID || history[0].Change != -wantGain {
+		t.Errorf("Unexpected history for Bob: %+v", history)
+	}
+
+	// Recomputing from scratch yields the same ratings
+	games, err := s.RecomputeRatings()
+	if err != nil {
+		t.Fatalf("Failed to recompute ratings: %v", err)
+	}
+	if games != 1 {
+		t.Errorf("Expected 1 game replayed, got %d", games)
+	}
+	recomputed, err := s.GetRatingLeaderboard()
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	for i := range entries {
+		if recomputed[i].UserID != entries[i].UserID || recomputed[i].Rating != entries[i].Rating {
+			t.Errorf("Expected %+v after recompute, got %+v", entries[i], recomputed[i])
+		}
+	}
+
+	// Archived players keep their rating but lose their rank
+	if err := s.DeleteUser(alice); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	entries, err = s.GetRatingLeaderboard()
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 1 || entries[0].UserID != bob || entries[0].Rank != 1 {
+		t.Errorf("Expected Bob alone on top after archiving Alice, got %+v", entries)
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SQLiteOptions configures the connections to a SQLite database
+type SQLiteOptions struct {
+	// JournalMode is the journal_mode pragma. In WAL mode readers are not
+	// blocked while a throw is written.
+	JournalMode string
+	// Synchronous is the synchronous pragma. NORMAL is safe with WAL; a power
+	// loss may only undo the last transactions.
+	Synchronous string
+	// BusyTimeout is how long a statement waits for a locked database,
+	// e.g. while a maintenance command writes, before it fails
+	BusyTimeout time.Duration
+	// ForeignKeys enforces the foreign keys declared in the schema
+	ForeignKeys bool
+	// ReadConnections is the size of the read-only pool used by queries.
+	// Writes always go through a single connection. With 0, reads share it.
+	ReadConnections int
+}
+
+var (
+	sqliteJournalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	sqliteSynchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// DefaultSQLiteOptions returns the settings used by NewStore
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode:     "WAL",
+		Synchronous:     "NORMAL",
+		BusyTimeout:     5 * time.Second,
+		ForeignKeys:     true,
+		ReadConnections: 4,
+	}
+}
+
+// pragmas returns the statements run on every new connection. Read-only
+// connections leave the journal mode, a database setting, to the writer.
+func (o SQLiteOptions) pragmas(readOnly bool) ([]string, error) {
+	journalMode := strings.ToUpper(o.JournalMode)
+	if !slices.Contains(sqliteJournalModes, journalMode) {
+		return nil, fmt.Errorf("invalid SQLite journal mode %q", o.JournalMode)
+	}
+	synchronous := strings.ToUpper(o.Synchronous)
+	if !slices.Contains(sqliteSynchronous, synchronous) {
+		return nil, fmt.Errorf("invalid SQLite synchronous level %q", o.Synchronous)
+	}
+	if o.BusyTimeout < 0 || o.ReadConnections < 0 {
+		return nil, fmt.Errorf("invalid SQLite options %+v", o)
+	}
+
+	foreignKeys := "OFF"
+	if o.ForeignKeys {
+		foreignKeys = "ON"
+	}
+	pragmas := []string{
+		fmt.Sprintf("busy_timeout = %d", o.BusyTimeout.Milliseconds()),
+		"foreign_keys = " + foreignKeys,
+		"synchronous = " + synchronous,
+	}
+	if readOnly {
+		return append(pragmas, "query_only = ON"), nil
+	}
+	return append([]string{"journal_mode = " + journalMode}, pragmas...), nil
+}
+
+// openSQLite opens the write connection and, if configured, the read pool
+func openSQLite(dsn string, opts SQLiteOptions) (*SQLStore, error) {
+	writePragmas, err := opts.pragmas(false)
+	if err != nil {
+		return nil, err
+	}
+	readPragmas, err := opts.pragmas(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows one writer at a time. Queuing writes for a single
+	// connection in Go avoids lock errors between them.
+	db := sql.OpenDB(&sqliteConnector{dsn: dsn, pragmas: writePragmas})
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLStore{db: &conn{DB: db, dialect: dialectSQLite}}
+	s.read = s.db
+	if opts.ReadConnections > 0 {
+		read := sql.OpenDB(&sqliteConnector{dsn: dsn, pragmas: readPragmas})
+		read.SetMaxOpenConns(opts.ReadConnections)
+		read.SetMaxIdleConns(opts.ReadConnections)
+		if err := read.Ping(); err != nil {
+			read.Close()
+			db.Close()
+			return nil, err
+		}
+		s.read = &conn{DB: read, dialect: dialectSQLite}
+	}
+	return s, nil
+}
+
+// sqliteDriverImpl is the driver registered as sqliteDriver. sql.Open does
+// not connect, so it only serves to look the driver up.
+var sqliteDriverImpl = func() driver.Driver {
+	db, _ := sql.Open(sqliteDriver, "")
+	defer db.Close()
+	return db.Driver()
+}()
+
+// sqliteConnector applies the pragmas to every connection it opens, since
+// settings like foreign_keys only affect the connection they run on
+type sqliteConnector struct {
+	dsn     string
+	pragmas []string
+}
+
+func (c *sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range c.pragmas {
+		if err := execPragma(ctx, conn, p); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("PRAGMA %s: %w", p, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return sqliteDriverImpl
+}
+
+func execPragma(ctx context.Context, conn driver.Conn, pragma string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, "PRAGMA "+pragma, nil)
+		return err
+	}
+	stmt, err := conn.Prepare("PRAGMA " + pragma)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
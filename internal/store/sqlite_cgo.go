@@ -0,0 +1,19 @@
+//go:build !sqlite_purego
+
+package store
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the database/sql driver used for SQLite. The default build
+// uses the cgo driver; build with -tags sqlite_purego for the pure Go one.
+const sqliteDriver = "sqlite3"
+
+// isSQLiteUniqueViolation reports whether err is a failed UNIQUE constraint
+func isSQLiteUniqueViolation(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && e.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,20 @@
+//go:build sqlite_purego
+
+package store
+
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteDriver is the pure Go SQLite driver, which needs no C toolchain and
+// allows CGO_ENABLED=0 builds
+const sqliteDriver = "sqlite"
+
+// isSQLiteUniqueViolation reports whether err is a failed UNIQUE constraint
+func isSQLiteUniqueViolation(err error) bool {
+	var e *sqlite.Error
+	return errors.As(err, &e) && e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/game"
+)
+
+func TestNewStore_ConnectionSettings(t *testing.T) {
+	s, err := NewStore(filepath.Join(t.TempDir(), "darts.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	var journalMode string
+	var foreignKeys, busyTimeout int
+	if err := s.db.QueryRow(`PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+		t.Fatalf("Failed to read journal mode: %v", err)
+	}
+	if err := s.read.QueryRow(`PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+		t.Fatalf("Failed to read foreign keys: %v", err)
+	}
+	if err := s.read.QueryRow(`PRAGMA busy_timeout`).Scan(&busyTimeout); err != nil {
+		t.Fatalf("Failed to read busy timeout: %v", err)
+	}
+	if journalMode != "wal" || foreignKeys != 1 || busyTimeout != 5000 {
+		t.Errorf("Expected wal, foreign keys on and 5000ms, got %s, %d, %dms", journalMode, foreignKeys, busyTimeout)
+	}
+
+	if _, err := s.read.Exec(`INSERT INTO users (name) VALUES ('Reader')`); err == nil {
+		t.Error("Expected the read pool to refuse writes")
+	}
+	if _, err := s.db.Exec(`INSERT INTO game_players (game_id, user_id, player_order) VALUES (42, 42, 0)`); err == nil {
+		t.Error("Expected a foreign key error for a missing game")
+	}
+}
+
+func TestNewStoreWithOptions_Invalid(t *testing.T) {
+	opts := DefaultSQLiteOptions()
+	opts.Synchronous = "SOMETIMES"
+	if _, err := NewStoreWithOptions(filepath.Join(t.TempDir(), "darts.db"), opts); err == nil {
+		t.Error("Expected an error for an invalid synchronous level")
+	}
+}
+
+// TestConcurrentThrowsAndStats records throws while other goroutines read
+// statistics, as the scoreboard and stats pages do during a game
+func TestConcurrentThrowsAndStats(t *testing.T) {
+	skipOnPostgres(t)
+	s, err := NewStore(filepath.Join(t.TempDir(), "darts.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, err := s.CreateUser("Alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := s.CreateUser("Bob")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	const games = 20
+	done := make(chan struct{})
+	errs := make(chan error, 64)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		engine := game.NewEngine()
+		for i := 0; i < games; i++ {
+			g, err := s.CreateGame(101, 1, true, []int{alice.ID, bob.ID})
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, d := range bestOfThreeDarts(alice.ID, bob.ID)[:7] {
+				throw, err := engine.ProcessThrow(g, d.userID, d.points, d.multiplier)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.RecordThrow(throw, g); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.GetLiveStats(g); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := s.GetUserStats(alice.ID); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.GetLeaderboard(MetricAverage3Dart, time.Time{}, 0); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.GetUserHeatmap(bob.ID, ThrowFilter{}); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.ListUsers(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent access failed: %v", err)
+	}
+
+	var throws int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM throws`).Scan(&throws); err != nil {
+		t.Fatalf("Failed to count throws: %v", err)
+	}
+	if want := games * 7; throws != want {
+		t.Errorf("Expected %d throws, got %d", want, throws)
+	}
+	stats, err := s.GetUserStats(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalGames != games || stats.Wins != games {
+		t.Errorf("Expected %d games won in the stats, got %d of %d", games, stats.Wins, stats.TotalGames)
+	}
+}
@@ -1,52 +1,374 @@
 package store
 
-import "github.com/michaelschlottmann/darts-web/internal/models"
+import (
+	"database/sql"
 
-func (s *Store) GetUserStats(userID int) (map[string]interface{}, error) {
-	// Simple stats: Total Games, Games Won
-	var totalGames int
-	// Only count FINISHED games
-	err := s.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM game_players gp
-		JOIN games g ON gp.game_id = g.id
-		WHERE gp.user_id = ? AND g.status = ?`, userID, models.GameStatusFinished).Scan(&totalGames)
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// scoreBeforeExpr is the player's score before a dart: the previous
+// score_after in the leg (a bust reports the reverted score, which is what the
+// next dart is thrown at), or the starting points. Requires throws aliased as
+// t and games as g.
+const scoreBeforeExpr = `COALESCE(LAG(t.score_after) OVER (PARTITION BY t.game_id, t.set_no, t.leg_no, t.user_id ORDER BY t.id), g.total_points)`
+
+// checkoutAttemptCondition matches darts thrown with a one-dart double
+// finish left, given a score_before column
+const checkoutAttemptCondition = `(score_before = 50 OR (score_before BETWEEN 2 AND 40 AND score_before % 2 = 0))`
+
+// UserStats contains a player's statistics across all finished games
+type UserStats struct {
+	TotalGames   int     `json:"total_games"`
+	Wins         int     `json:"wins"`
+	Average3Dart float64 `json:"average_3_dart"`
+	TotalThrows  int     `json:"total_throws"`
+
+	// Average of the first nine darts of every leg
+	First9Average float64 `json:"first_9_average"`
+
+	// Darts thrown with a one-dart double finish left (2-40 even, or 50)
+	// and how many of those finished the leg
+	CheckoutAttempts   int     `json:"checkout_attempts"`
+	CheckoutHits       int     `json:"checkout_hits"`
+	CheckoutPercentage float64 `json:"checkout_percentage"`
+	HighestCheckout    int     `json:"highest_checkout"`
+
+	// Visit scores in the bands 100-139, 140-179 and exactly 180
+	Tons100Plus int `json:"tons_100_plus"`
+	Tons140Plus int `json:"tons_140_plus"`
+	Maximums180 int `json:"maximums_180"`
+
+	LegsPlayed  int     `json:"legs_played"`
+	LegsWon     int     `json:"legs_won"`
+	Busts       int     `json:"busts"`
+	BustsPerLeg float64 `json:"busts_per_leg"`
+
+	// Darts needed for legs the player won
+	BestLegDarts       int     `json:"best_leg_darts"`
+	WorstLegDarts      int     `json:"worst_leg_darts"`
+	AverageDartsPerLeg float64 `json:"average_darts_per_leg"`
+}
+
+// queryer is implemented by both conn and txn
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// userStatsTotals are the additive sums behind UserStats. They are stored
+// per player in user_stats and extended whenever a game finishes.
+type userStatsTotals struct {
+	games            int
+	wins             int
+	throws           int
+	points           int
+	first9Throws     int
+	first9Points     int
+	checkoutAttempts int
+	checkoutHits     int
+	highestCheckout  int
+	tons100          int
+	tons140          int
+	maximums180      int
+	busts            int
+	legsPlayed       int
+	legsWon          int
+	bestLegDarts     int // 0 if no leg was won
+	worstLegDarts    int
+	wonLegDarts      int
+}
+
+// RebuildStatsAggregates recalculates the statistics aggregate tables from
+// the throws and returns the number of finished games included
+func (s *SQLStore) RebuildStatsAggregates() (int, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	var gamesWon int
-	err = s.db.QueryRow(`SELECT COUNT(*) FROM games WHERE winner_id = ? AND status = ?`, userID, models.GameStatusFinished).Scan(&gamesWon)
+	games, err := rebuildStatsAggregates(tx)
 	if err != nil {
+		return 0, err
+	}
+
+	return games, tx.Commit()
+}
+
+func rebuildStatsAggregates(tx *txn) (int, error) {
+	_, err := tx.Exec(`
+		DELETE FROM game_player_set_stats;
+		DELETE FROM user_stats;
+
+		INSERT INTO game_player_set_stats (game_id, user_id, set_no, throws, points)
+		SELECT game_id, user_id, set_no,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN valid = 1 THEN points * multiplier ELSE 0 END), 0)
+		FROM throws
+		GROUP BY game_id, user_id, set_no;
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT id FROM games WHERE status = ? ORDER BY id`, models.GameStatusFinished)
+	if err != nil {
+		return 0, err
+	}
+	var gameIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		gameIDs = append(gameIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range gameIDs {
+		if err := addGameToUserStats(tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(gameIDs), nil
+}
+
+// addThrowToGameStats adds a saved throw to the per game aggregates.
+// Bust throws count toward the throw total but not toward points.
+func addThrowToGameStats(tx *txn, t *models.Throw) error {
+	points := 0
+	if t.Valid {
+		points = t.Points * t.Multiplier
+	}
+	_, err := tx.Exec(`
+		INSERT INTO game_player_set_stats (game_id, user_id, set_no, throws, points)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT(game_id, set_no, user_id) DO UPDATE SET
+			throws = game_player_set_stats.throws + 1,
+			points = game_player_set_stats.points + excluded.points`,
+		t.GameID, t.UserID, t.SetNo, points)
+	return err
+}
+
+// addGameToUserStats adds a just finished game to the totals of its players
+func addGameToUserStats(tx *txn, gameID int) error {
+	players, err := queryGamePlayers(tx, gameID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range players {
+		t, err := scanUserStatsTotals(tx, p.UserID, gameID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO user_stats (user_id, games, wins, throws, points, first9_throws, first9_points,
+				checkout_attempts, checkout_hits, highest_checkout, tons_100, tons_140, maximums_180,
+				busts, legs_played, legs_won, best_leg_darts, worst_leg_darts, won_leg_darts)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(user_id) DO UPDATE SET
+				games = user_stats.games + excluded.games,
+				wins = user_stats.wins + excluded.wins,
+				throws = user_stats.throws + excluded.throws,
+				points = user_stats.points + excluded.points,
+				first9_throws = user_stats.first9_throws + excluded.first9_throws,
+				first9_points = user_stats.first9_points + excluded.first9_points,
+				checkout_attempts = user_stats.checkout_attempts + excluded.checkout_attempts,
+				checkout_hits = user_stats.checkout_hits + excluded.checkout_hits,
+				highest_checkout = CASE
+					WHEN excluded.highest_checkout > user_stats.highest_checkout THEN excluded.highest_checkout
+					ELSE user_stats.highest_checkout
+				END,
+				tons_100 = user_stats.tons_100 + excluded.tons_100,
+				tons_140 = user_stats.tons_140 + excluded.tons_140,
+				maximums_180 = user_stats.maximums_180 + excluded.maximums_180,
+				busts = user_stats.busts + excluded.busts,
+				legs_played = user_stats.legs_played + excluded.legs_played,
+				legs_won = user_stats.legs_won + excluded.legs_won,
+				best_leg_darts = CASE
+					WHEN user_stats.best_leg_darts = 0 THEN excluded.best_leg_darts
+					WHEN excluded.best_leg_darts = 0 THEN user_stats.best_leg_darts
+					WHEN excluded.best_leg_darts < user_stats.best_leg_darts THEN excluded.best_leg_darts
+					ELSE user_stats.best_leg_darts
+				END,
+				worst_leg_darts = CASE
+					WHEN excluded.worst_leg_darts > user_stats.worst_leg_darts THEN excluded.worst_leg_darts
+					ELSE user_stats.worst_leg_darts
+				END,
+				won_leg_darts = user_stats.won_leg_darts + excluded.won_leg_darts`,
+			p.UserID, t.games, t.wins, t.throws, t.points, t.first9Throws, t.first9Points,
+			t.checkoutAttempts, t.checkoutHits, t.highestCheckout, t.tons100, t.tons140, t.maximums180,
+			t.busts, t.legsPlayed, t.legsWon, t.bestLegDarts, t.worstLegDarts, t.wonLegDarts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserStats reads a player's statistics from the user_stats aggregate
+func (s *SQLStore) GetUserStats(userID int) (*UserStats, error) {
+	var t userStatsTotals
+	err := s.read.QueryRow(`
+		SELECT games, wins, throws, points, first9_throws, first9_points,
+			checkout_attempts, checkout_hits, highest_checkout, tons_100, tons_140, maximums_180,
+			busts, legs_played, legs_won, best_leg_darts, worst_leg_darts, won_leg_darts
+		FROM user_stats
+		WHERE user_id = ?`, userID).
+		Scan(&t.games, &t.wins, &t.throws, &t.points, &t.first9Throws, &t.first9Points,
+			&t.checkoutAttempts, &t.checkoutHits, &t.highestCheckout, &t.tons100, &t.tons140, &t.maximums180,
+			&t.busts, &t.legsPlayed, &t.legsWon, &t.bestLegDarts, &t.worstLegDarts, &t.wonLegDarts)
+	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 
-	// Average calculation: proper 3-dart average
-	var totalPoints, totalThrows int
-	// Only count throws from FINISHED games
+	return t.userStats(), nil
+}
+
+// userStats derives the averages and rates from the totals
+func (t userStatsTotals) userStats() *UserStats {
+	stats := &UserStats{
+		TotalGames:       t.games,
+		Wins:             t.wins,
+		TotalThrows:      t.throws,
+		CheckoutAttempts: t.checkoutAttempts,
+		CheckoutHits:     t.checkoutHits,
+		HighestCheckout:  t.highestCheckout,
+		Tons100Plus:      t.tons100,
+		Tons140Plus:      t.tons140,
+		Maximums180:      t.maximums180,
+		LegsPlayed:       t.legsPlayed,
+		LegsWon:          t.legsWon,
+		Busts:            t.busts,
+		BestLegDarts:     t.bestLegDarts,
+		WorstLegDarts:    t.worstLegDarts,
+	}
+
+	if t.throws > 0 {
+		// 3-dart average = (total points / total throws) * 3
+		// This gives the average points per 3 darts
+		stats.Average3Dart = (float64(t.points) / float64(t.throws)) * 3
+	}
+	if t.first9Throws > 0 {
+		stats.First9Average = (float64(t.first9Points) / float64(t.first9Throws)) * 3
+	}
+	if t.checkoutAttempts > 0 {
+		stats.CheckoutPercentage = float64(t.checkoutHits) / float64(t.checkoutAttempts) * 100
+	}
+	if t.legsPlayed > 0 {
+		stats.BustsPerLeg = float64(t.busts) / float64(t.legsPlayed)
+	}
+	if t.legsWon > 0 {
+		stats.AverageDartsPerLeg = float64(t.wonLegDarts) / float64(t.legsWon)
+	}
+	return stats
+}
+
+// scanUserStatsTotals calculates a player's totals directly from the throws,
+// visits and legs of finished games. A gameID of 0 covers all games,
+// otherwise only that game is scanned.
+func scanUserStatsTotals(q queryer, userID, gameID int) (userStatsTotals, error) {
+	var t userStatsTotals
+	finished := models.GameStatusFinished
+
+	// Simple stats: Total Games, Games Won
+	// Only count FINISHED games
+	err := q.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN g.winner_id = gp.user_id THEN 1 ELSE 0 END), 0)
+		FROM game_players gp
+		JOIN games g ON gp.game_id = g.id
+		WHERE gp.user_id = ? AND g.status = ? AND (? = 0 OR g.id = ?)`,
+		userID, finished, gameID, gameID).Scan(&t.games, &t.wins)
+	if err != nil {
+		return t, err
+	}
+
 	// Only count points from VALID throws (not busts), but count ALL throws
-	err = s.db.QueryRow(`
+	err = q.QueryRow(`
 		SELECT
 			COALESCE(SUM(CASE WHEN t.valid = 1 THEN t.points * t.multiplier ELSE 0 END), 0) as total_points,
 			COUNT(*) as total_throws
 		FROM throws t
 		JOIN games g ON t.game_id = g.id
-		WHERE t.user_id = ? AND g.status = ?`, userID, models.GameStatusFinished).Scan(&totalPoints, &totalThrows)
+		WHERE t.user_id = ? AND g.status = ? AND (? = 0 OR g.id = ?)`,
+		userID, finished, gameID, gameID).Scan(&t.points, &t.throws)
 	if err != nil {
-		return nil, err
+		return t, err
 	}
 
-	average := 0.0
-	if totalThrows > 0 {
-		// 3-dart average = (total points / total throws) * 3
-		// This gives the average points per 3 darts
-		average = (float64(totalPoints) / float64(totalThrows)) * 3
+	// First 9: the player's first nine darts of each leg
+	err = q.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN valid = 1 THEN points * multiplier ELSE 0 END), 0),
+			COUNT(*)
+		FROM (
+			SELECT t.valid, t.points, t.multiplier,
+				ROW_NUMBER() OVER (PARTITION BY t.game_id, t.set_no, t.leg_no ORDER BY t.id) AS dart_in_leg
+			FROM throws t
+			JOIN games g ON t.game_id = g.id
+			WHERE t.user_id = ? AND g.status = ? AND (? = 0 OR g.id = ?)
+		) first9
+		WHERE dart_in_leg <= 9`,
+		userID, finished, gameID, gameID).Scan(&t.first9Points, &t.first9Throws)
+	if err != nil {
+		return t, err
+	}
+
+	// Checkout attempts and hits
+	err = q.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN valid = 1 AND score_after = 0 THEN 1 ELSE 0 END), 0)
+		FROM (
+			SELECT t.valid, t.score_after, `+scoreBeforeExpr+` AS score_before
+			FROM throws t
+			JOIN games g ON t.game_id = g.id
+			WHERE t.user_id = ? AND g.status = ? AND (? = 0 OR g.id = ?)
+		) darts
+		WHERE `+checkoutAttemptCondition,
+		userID, finished, gameID, gameID).Scan(&t.checkoutAttempts, &t.checkoutHits)
+	if err != nil {
+		return t, err
+	}
+
+	// Visit based counts
+	err = q.QueryRow(`
+		SELECT
+			COALESCE(MAX(CASE WHEN v.checkout = 1 THEN v.score_before END), 0),
+			COALESCE(SUM(CASE WHEN v.bust = 0 AND v.score_before - v.score_after BETWEEN 100 AND 139 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN v.bust = 0 AND v.score_before - v.score_after BETWEEN 140 AND 179 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN v.bust = 0 AND v.score_before - v.score_after = 180 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(v.bust), 0),
+			COUNT(DISTINCT v.leg_id)
+		FROM visits v
+		JOIN games g ON v.game_id = g.id
+		WHERE v.user_id = ? AND g.status = ? AND (? = 0 OR g.id = ?)`,
+		userID, finished, gameID, gameID).
+		Scan(&t.highestCheckout, &t.tons100, &t.tons140, &t.maximums180, &t.busts, &t.legsPlayed)
+	if err != nil {
+		return t, err
+	}
+
+	// Darts per won leg
+	err = q.QueryRow(`
+		SELECT COUNT(*), COALESCE(MIN(darts), 0), COALESCE(MAX(darts), 0), COALESCE(SUM(darts), 0)
+		FROM (
+			SELECT SUM(v.darts) AS darts
+			FROM legs l
+			JOIN games g ON l.game_id = g.id
+			JOIN visits v ON v.leg_id = l.id AND v.user_id = l.winner_id
+			WHERE l.winner_id = ? AND g.status = ? AND (? = 0 OR g.id = ?)
+			GROUP BY l.id
+		) won_legs`,
+		userID, finished, gameID, gameID).
+		Scan(&t.legsWon, &t.bestLegDarts, &t.worstLegDarts, &t.wonLegDarts)
+	if err != nil {
+		return t, err
 	}
 
-	return map[string]interface{}{
-		"total_games":    totalGames,
-		"wins":           gamesWon,
-		"average_3_dart": average,
-		"total_throws":   totalThrows,
-	}, nil
+	return t, nil
 }
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/michaelschlottmann/darts-web/internal/game"
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// seedRandomGames plays random best-of-3 501 games between two players in a
+// single transaction and returns their user IDs
+func seedRandomGames(tb testing.TB, s *SQLStore, games int) (int, int) {
+	tb.Helper()
+	alice, err := s.CreateUser("Alice")
+	if err != nil {
+		tb.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := s.CreateUser("Bob")
+	if err != nil {
+		tb.Fatalf("Failed to create user: %v", err)
+	}
+
+	created := make([]*models.Game, games)
+	for i := range created {
+		created[i], err = s.CreateGame(501, 3, true, []int{alice.ID, bob.ID})
+		if err != nil {
+			tb.Fatalf("Failed to create game: %v", err)
+		}
+	}
+
+	engine := game.NewEngine()
+	rng := rand.New(rand.NewSource(1))
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		tb.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, g := range created {
+		for g.WinnerID == nil {
+			player := g.Players[g.CurrentTurn.PlayerIndex]
+			points, multiplier := rng.Intn(21), rng.Intn(3)+1
+			if points == 0 {
+				multiplier = 1
+			}
+			// Aim for a double when on a finish
+			if player.CurrentPoints <= 40 && player.CurrentPoints%2 == 0 && rng.Intn(2) == 0 {
+				points, multiplier = player.CurrentPoints/2, 2
+			}
+			throw, err := engine.ProcessThrow(g, player.UserID, points, multiplier)
+			if err != nil {
+				tb.Fatalf("ProcessThrow() error = %v", err)
+			}
+			if err := saveThrow(tx, throw); err != nil {
+				tb.Fatalf("Failed to save throw: %v", err)
+			}
+			if err := updateGame(tx, g); err != nil {
+				tb.Fatalf("Failed to update game: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tb.Fatalf("Failed to commit: %v", err)
+	}
+	return alice.ID, bob.ID
+}
+
+func TestUserStatsAggregates_MatchFullScan(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, bob := seedRandomGames(t, s, 20)
+
+	check := func(stage string) {
+		for _, uid := range []int{alice, bob} {
+			got, err := s.GetUserStats(uid)
+			if err != nil {
+				t.Fatalf("Failed to get user stats: %v", err)
+			}
+			totals, err := scanUserStatsTotals(s.db, uid, 0)
+			if err != nil {
+				t.Fatalf("Failed to scan user stats: %v", err)
+			}
+			if want := totals.userStats(); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: user %d aggregates %+v differ from full scan %+v", stage, uid, got, want)
+			}
+		}
+	}
+
+	check("incremental")
+
+	games, err := s.RebuildStatsAggregates()
+	if err != nil {
+		t.Fatalf("Failed to rebuild aggregates: %v", err)
+	}
+	if games != 20 {
+		t.Errorf("Expected 20 games rebuilt, got %d", games)
+	}
+	check("rebuilt")
+}
+
+func benchmarkStore(b *testing.B) (*SQLStore, int) {
+	s, err := openTestStore(b)
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	b.Cleanup(func() { s.Close() })
+
+	alice, _ := seedRandomGames(b, s, 200)
+	return s, alice
+}
+
+// BenchmarkGetUserStats_Aggregates reads the user_stats aggregate
+func BenchmarkGetUserStats_Aggregates(b *testing.B) {
+	s, alice := benchmarkStore(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetUserStats(alice); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetUserStats_FullScan calculates the same statistics from all throws
+func BenchmarkGetUserStats_FullScan(b *testing.B) {
+	s, alice := benchmarkStore(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scanUserStatsTotals(s.db, alice, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
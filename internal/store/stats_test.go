@@ -0,0 +1,312 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGetUserStats(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, _ := s.CreateUser("Alice")
+	bob, _ := s.CreateUser("Bob")
+	g, err := s.CreateGame(301, 1, true, []int{alice.ID, bob.ID})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	playDarts(t, s, g.ID, []testDart{
+		{alice.ID, 20, 3}, {alice.ID, 20, 3}, {alice.ID, 20, 3}, // 180, 301 -> 121
+		{bob.ID, 20, 1}, {bob.ID, 20, 1}, {bob.ID, 20, 1}, // 301 -> 241
+		{alice.ID, 20, 3}, {alice.ID, 19, 3}, {alice.ID, 20, 3}, // 61 -> 4 -> bust at a double
+		{bob.ID, 0, 1}, {bob.ID, 0, 1}, {bob.ID, 0, 1},
+		{alice.ID, 20, 3}, {alice.ID, 19, 3}, {alice.ID, 2, 2}, // 121 checkout
+	})
+
+	stats, err := s.GetUserStats(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user stats: %v", err)
+	}
+
+	if stats.TotalGames != 1 || stats.Wins != 1 || stats.TotalThrows != 9 {
+		t.Errorf("Expected 1 game, 1 win, 9 throws, got %+v", stats)
+	}
+	// 180 + 117 (bust dart scores nothing) + 121 = 418 points in 9 darts
+	wantAverage := 418.0 / 9 * 3
+	if math.Abs(stats.Average3Dart-wantAverage) > 0.001 || math.Abs(stats.First9Average-wantAverage) > 0.001 {
+		t.Errorf("Expected average and first 9 of %.2f, got %.2f and %.2f", wantAverage, stats.Average3Dart, stats.First9Average)
+	}
+	if stats.CheckoutAttempts != 2 || stats.CheckoutHits != 1 || stats.CheckoutPercentage != 50 {
+		t.Errorf("Expected 1 of 2 checkout darts (50%%), got %d of %d (%.1f%%)",
+			stats.CheckoutHits, stats.CheckoutAttempts, stats.CheckoutPercentage)
+	}
+	if stats.HighestCheckout != 121 {
+		t.Errorf("Expected highest checkout 121, got %d", stats.HighestCheckout)
+	}
+	if stats.Tons100Plus != 1 || stats.Tons140Plus != 0 || stats.Maximums180 != 1 {
+		t.Errorf("Expected one 100+ and one 180, got %d/%d/%d", stats.Tons100Plus, stats.Tons140Plus, stats.Maximums180)
+	}
+	if stats.LegsPlayed != 1 || stats.LegsWon != 1 || stats.Busts != 1 || stats.BustsPerLeg != 1 {
+		t.Errorf("Expected 1 leg won with 1 bust, got %+v", stats)
+	}
+	if stats.BestLegDarts != 9 || stats.WorstLegDarts != 9 || stats.AverageDartsPerLeg != 9 {
+		t.Errorf("Expected a 9 dart leg, got best %d, worst %d, average %.1f",
+			stats.BestLegDarts, stats.WorstLegDarts, stats.AverageDartsPerLeg)
+	}
+
+	bobStats, err := s.GetUserStats(bob.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user stats: %v", err)
+	}
+	if bobStats.Wins != 0 || bobStats.LegsWon != 0 || bobStats.BestLegDarts != 0 || bobStats.CheckoutAttempts != 0 {
+		t.Errorf("Expected no wins or checkout attempts for Bob, got %+v", bobStats)
+	}
+}
+
+func TestGetUserHeatmap(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, _ := s.CreateUser("Alice")
+	g, err := s.CreateGame(501, 1, false, []int{alice.ID})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	playDarts(t, s, g.ID, []testDart{
+		{alice.ID, 20, 3}, {alice.ID, 20, 1}, {alice.ID, 19, 3},
+		{alice.ID, 25, 2}, {alice.ID, 25, 1}, {alice.ID, 0, 1},
+	})
+
+	heatmap, err := s.GetUserHeatmap(alice.ID, ThrowFilter{})
+	if err != nil {
+		t.Fatalf("Failed to get heatmap: %v", err)
+	}
+	if heatmap.TotalDarts != 6 || heatmap.Misses != 1 || heatmap.Bull.Inner != 1 || heatmap.Bull.Outer != 1 {
+		t.Errorf("Unexpected totals: %+v", heatmap)
+	}
+	twenty := heatmap.Segments[19]
+	if twenty.Segment != 20 || twenty.Trebles != 1 || twenty.Singles != 1 || twenty.Total != 2 {
+		t.Errorf("Unexpected 20 segment: %+v", twenty)
+	}
+	if heatmap.Segments[18].Trebles != 1 || heatmap.Segments[18].TrebleRate != twenty.TrebleRate {
+		t.Errorf("Expected equal treble rates for 19 and 20, got %+v and %+v", heatmap.Segments[18], twenty)
+	}
+
+	other, err := s.GetUserHeatmap(alice.ID, ThrowFilter{GameID: g.ID + 1})
+	if err != nil {
+		t.Fatalf("Failed to get heatmap: %v", err)
+	}
+	if other.TotalDarts != 0 {
+		t.Errorf("Expected game filter to exclude all darts, got %d", other.TotalDarts)
+	}
+}
+
+func TestGetUserTimeSeries(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, _ := s.CreateUser("Alice")
+	var lastGame int
+	for i, day := range []string{"2026-03-02 10:00:00", "2026-03-08 20:00:00", "2026-03-09 10:00:00"} {
+		g, err := s.CreateGame(301, 1, false, []int{alice.ID})
+		if err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		lastGame = g.ID
+		// Game i scores (i+1) * 20 per dart before checking out
+		darts := []testDart{{alice.ID, 20, i + 1}}
+		playDarts(t, s, g.ID, darts)
+		if _, err := s.db.Exec(`UPDATE games SET status = ?, created_at = ? WHERE id = ?`, "FINISHED", day, g.ID); err != nil {
+			t.Fatalf("Failed to finish game: %v", err)
+		}
+		if _, err := s.db.Exec(`UPDATE throws SET created_at = ? WHERE game_id = ?`, day, g.ID); err != nil {
+			t.Fatalf("Failed to date throws: %v", err)
+		}
+	}
+
+	series, err := s.GetUserTimeSeries(alice.ID, MetricAverage3Dart, "week", ThrowFilter{})
+	if err != nil {
+		t.Fatalf("Failed to get time series: %v", err)
+	}
+	// Monday 2 March covers the first two games, Monday 9 March the third
+	if len(series.Points) != 2 {
+		t.Fatalf("Expected 2 weekly buckets, got %+v", series.Points)
+	}
+	if series.Points[0].Bucket != "2026-03-02" || series.Points[0].Samples != 2 || series.Points[0].Value != 90 {
+		t.Errorf("Unexpected first bucket: %+v", series.Points[0])
+	}
+	if series.Points[1].Bucket != "2026-03-09" || series.Points[1].Value != 180 {
+		t.Errorf("Unexpected second bucket: %+v", series.Points[1])
+	}
+
+	games, err := s.GetUserTimeSeries(alice.ID, MetricGamesPlayed, "month", ThrowFilter{})
+	if err != nil {
+		t.Fatalf("Failed to get time series: %v", err)
+	}
+	if len(games.Points) != 1 || games.Points[0].Bucket != "2026-03-01" || games.Points[0].Value != 3 {
+		t.Errorf("Unexpected monthly games series: %+v", games.Points)
+	}
+	games, err = s.GetUserTimeSeries(alice.ID, MetricGamesPlayed, "month", ThrowFilter{GameID: lastGame})
+	if err != nil {
+		t.Fatalf("Failed to get time series: %v", err)
+	}
+	if len(games.Points) != 1 || games.Points[0].Value != 1 {
+		t.Errorf("Expected the one filtered game, got %+v", games.Points)
+	}
+
+	if _, err := s.GetUserTimeSeries(alice.ID, "nine_darters", "week", ThrowFilter{}); err != ErrUnknownMetric {
+		t.Errorf("Expected ErrUnknownMetric, got %v", err)
+	}
+	if _, err := s.GetUserTimeSeries(alice.ID, MetricAverage3Dart, "year", ThrowFilter{}); err != ErrUnknownBucket {
+		t.Errorf("Expected ErrUnknownBucket, got %v", err)
+	}
+}
+
+func TestGetHeadToHead(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+	carol, _ := s.CreateUser("Carol")
+
+	// Alice and Bob share one set each, then Alice wins the decider
+	darts := bestOfThreeDarts(alice, bob)
+	darts = append(darts,
+		testDart{alice, 20, 3}, testDart{alice, 20, 1}, testDart{alice, 1, 1}, // 101 -> 20
+		testDart{bob, 0, 1}, testDart{bob, 0, 1}, testDart{bob, 0, 1},
+		testDart{alice, 10, 2},
+	)
+	playDarts(t, s, g.ID, darts)
+
+	// A three player game won by Carol still counts as a shared game
+	multi, err := s.CreateGame(101, 1, false, []int{carol.ID, alice, bob})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	playDarts(t, s, multi.ID, []testDart{{carol.ID, 20, 3}, {carol.ID, 20, 2}, {carol.ID, 1, 1}})
+
+	h2h, err := s.GetHeadToHead(alice, bob, 10)
+	if err != nil {
+		t.Fatalf("Failed to get head-to-head: %v", err)
+	}
+	if h2h.GamesPlayed != 2 || len(h2h.RecentResults) != 2 {
+		t.Fatalf("Expected 2 shared games, got %d (%d results)", h2h.GamesPlayed, len(h2h.RecentResults))
+	}
+	if h2h.Player.Wins != 1 || h2h.Opponent.Wins != 0 {
+		t.Errorf("Expected 1-0 in wins, got %d-%d", h2h.Player.Wins, h2h.Opponent.Wins)
+	}
+	if h2h.Player.SetsWon != 2 || h2h.Opponent.SetsWon != 1 || h2h.SetDifferential != 1 {
+		t.Errorf("Expected 2-1 in sets, got %+v", h2h)
+	}
+	if h2h.Player.LegsWon != 2 || h2h.Opponent.LegsWon != 1 || h2h.LegDifferential != 1 {
+		t.Errorf("Expected 2-1 in legs, got %+v", h2h)
+	}
+	if h2h.RecentResults[0].GameID != multi.ID || h2h.RecentResults[0].PlayerCount != 3 {
+		t.Errorf("Expected the three player game first, got %+v", h2h.RecentResults[0])
+	}
+	if h2h.Player.Average3Dart == 0 || h2h.Opponent.Average3Dart == 0 {
+		t.Errorf("Expected averages for both players, got %+v", h2h)
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+	carol, _ := s.CreateUser("Carol")
+
+	// Alice wins 2-0 in 101 double out
+	playDarts(t, s, g.ID, append(bestOfThreeDarts(alice, bob)[:7],
+		testDart{bob, 0, 1}, testDart{bob, 0, 1}, testDart{bob, 0, 1},
+		testDart{alice, 20, 3}, testDart{alice, 1, 1}, testDart{alice, 20, 2},
+	))
+
+	// Carol's unfinished game does not count
+	solo, _ := s.CreateGame(501, 1, false, []int{carol.ID})
+	playDarts(t, s, solo.ID, []testDart{{carol.ID, 20, 3}, {carol.ID, 20, 3}, {carol.ID, 20, 3}})
+
+	entries, err := s.GetLeaderboard(MetricWinRate, time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 2 || entries[0].UserID != alice || entries[0].Value != 100 || entries[1].Value != 0 {
+		t.Errorf("Unexpected win rate leaderboard: %+v", entries)
+	}
+
+	entries, err = s.GetLeaderboard(MetricHighestCheckout, time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if entries[0].UserID != alice || entries[0].Value != 101 {
+		t.Errorf("Expected Alice's 101 checkout on top, got %+v", entries)
+	}
+
+	entries, err = s.GetLeaderboard(MetricAverage3Dart, time.Time{}, 2)
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected min_games to exclude everyone, got %+v", entries)
+	}
+
+	entries, err = s.GetLeaderboard(MetricMaximums180, time.Now().Add(time.Hour), 1)
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty period, got %+v", entries)
+	}
+
+	if _, err := s.GetLeaderboard("darts_thrown", time.Time{}, 1); err != ErrUnknownMetric {
+		t.Errorf("Expected ErrUnknownMetric, got %v", err)
+	}
+
+	// Deleting Bob archives him, which takes him off the leaderboards
+	if err := s.DeleteUser(bob); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	entries, err = s.GetLeaderboard(MetricWinRate, time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 1 || entries[0].UserID != alice {
+		t.Errorf("Expected only Alice after archiving Bob, got %+v", entries)
+	}
+}
+
+func TestGetLeaderboard_CheckoutPercentage(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	alice, _ := s.CreateUser("Alice")
+	bob, _ := s.CreateUser("Bob")
+
+	// Each player's score before a dart is their own previous score, not the
+	// opponent's: Alice attempts a double on 40 twice and hits the second,
+	// while Bob never stands on a finish
+	g, _ := s.CreateGame(101, 1, true, []int{alice.ID, bob.ID})
+	playDarts(t, s, g.ID, []testDart{
+		{alice.ID, 20, 3}, {alice.ID, 1, 1}, {alice.ID, 0, 1}, // 101 -> 40, one attempt
+		{bob.ID, 20, 1}, {bob.ID, 20, 1}, {bob.ID, 20, 1}, // 101 -> 41
+		{alice.ID, 20, 2}, // checkout
+	})
+
+	entries, err := s.GetLeaderboard(MetricCheckoutPercentage, time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("Failed to get leaderboard: %v", err)
+	}
+	if len(entries) != 2 || entries[0].UserID != alice.ID || entries[0].Value != 50 || entries[1].Value != 0 {
+		t.Errorf("Expected Alice with 50%% checkouts ahead of Bob with none, got %+v", entries)
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// Store is the persistence used by the handlers and maintenance commands.
+// SQLStore implements it on SQLite (NewStore) and PostgreSQL
+// (NewPostgresStore).
+type Store interface {
+	UserStore
+	GameStore
+	StatsStore
+	AuthStore
+
+	// Backup writes a snapshot of the database to path, or returns
+	// ErrBackupUnsupported if the database is backed up by other means
+	Backup(path string) error
+	Close() error
+}
+
+// UserStore manages players
+type UserStore interface {
+	CreateUser(name string) (*models.User, error)
+	ListUsers() ([]models.User, error)
+	ListArchivedUsers() ([]models.User, error)
+	SearchUsers(filter UserFilter) ([]models.User, int, error)
+	GetUser(id int) (*models.User, error)
+	UpdateUser(id int, update UserUpdate) (*models.User, error)
+	SetUserAvatar(id int, avatar string) (string, error)
+	DeleteUser(id int) error
+	UnarchiveUser(id int) error
+	PurgeUser(id int, mode PurgeMode) (int, error)
+	MergeUsers(into, from int, opts MergeOptions) (*MergeReport, error)
+}
+
+// GameStore manages games and their throws
+type GameStore interface {
+	CreateGame(totalPoints, bestOf int, doubleOut bool, playerIDs []int) (*models.Game, error)
+	GetGame(id int) (*models.Game, error)
+	RecordThrow(t *models.Throw, g *models.Game) ([]models.Achievement, error)
+	ImportGame(ig *ImportGame) (*models.Game, error)
+	GetGameTimeline(gameID int) (*Timeline, error)
+	ExportThrows(filter ThrowFilter, fn func(*ExportedThrow) error) error
+	ExportGames(filter ThrowFilter, fn func(*ExportedGame) error) error
+}
+
+// StatsStore reads and maintains statistics, ratings and achievements
+type StatsStore interface {
+	GetUserStats(userID int) (*UserStats, error)
+	GetGameStatistics(g *models.Game) (*GameStatistics, error)
+	GetLiveStats(g *models.Game) ([]LivePlayerStats, error)
+	GetUserHeatmap(userID int, filter ThrowFilter) (*Heatmap, error)
+	GetUserTimeSeries(userID int, metric, bucket string, filter ThrowFilter) (*TimeSeries, error)
+	GetHeadToHead(userID, otherID, recentLimit int) (*HeadToHead, error)
+	GetLeaderboard(metric string, since time.Time, minGames int) ([]LeaderboardEntry, error)
+	RebuildStatsAggregates() (int, error)
+
+	ApplyGameRatings(gameID int) error
+	RecomputeRatings() (int, error)
+	GetRatingLeaderboard() ([]RatingEntry, error)
+	GetRatingHistory(userID int) ([]RatingChange, error)
+
+	EvaluateAchievements(t *models.Throw, g *models.Game) ([]models.Achievement, error)
+	GetAchievements(userID int) ([]models.Achievement, error)
+}
+
+// AuthStore manages the accounts of the web UI, their sessions and the API
+// tokens. Sessions and tokens are looked up by the hash of the token.
+type AuthStore interface {
+	CreateAccount(username, passwordHash string, role models.Role) (*models.Account, error)
+	GetAccountByUsername(username string) (*models.Account, string, error)
+	ListAccounts() ([]models.Account, error)
+	SetAccountPassword(id int, passwordHash string) error
+	DeleteAccount(id int) error
+
+	CreateSession(tokenHash string, accountID int, expiresAt time.Time) error
+	GetSession(tokenHash string) (*models.Account, error)
+	DeleteSession(tokenHash string) error
+
+	CreateAPIToken(name string, role models.Role, tokenHash string) (*models.APIToken, error)
+	UseAPIToken(tokenHash string) (*models.APIToken, error)
+	ListAPITokens() ([]models.APIToken, error)
+	DeleteAPIToken(id int) error
+}
+
+var _ Store = (*SQLStore)(nil)
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+// openTestStore opens the store a test runs against, see NewTestStore
+func openTestStore(tb testing.TB) (*SQLStore, error) {
+	tb.Helper()
+	return NewTestStore()
+}
+
+// skipOnPostgres skips tests of SQLite specific behaviour
+func skipOnPostgres(tb testing.TB) {
+	tb.Helper()
+	if os.Getenv("TEST_POSTGRES_DSN") != "" {
+		tb.Skip("SQLite only")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	got := dialectPostgres.rebind(`SELECT ? FROM t WHERE a = '?' AND b IN (?, ?)`)
+	want := `SELECT $1 FROM t WHERE a = '?' AND b IN ($2, $3)`
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	query := `SELECT ? FROM t`
+	if got := dialectSQLite.rebind(query); got != query {
+		t.Errorf("Expected SQLite query unchanged, got %q", got)
+	}
+}
+
+func TestNewMemoryStore_Isolated(t *testing.T) {
+	a, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer a.Close()
+	b, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer b.Close()
+
+	if _, err := a.CreateUser("Alice"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	users, err := b.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("Expected the second store to be empty, got %v", users)
+	}
+
+	// The database must outlive idle connections
+	users, err = a.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("Expected 1 user, got %d", len(users))
+	}
+}
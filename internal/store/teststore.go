@@ -0,0 +1,30 @@
+package store
+
+import "os"
+
+// NewTestStore opens the store tests run against. By default that is an
+// in-memory SQLite database; with TEST_POSTGRES_DSN set it is the PostgreSQL
+// database there, emptied first. Packages sharing that database must not
+// run their tests in parallel (go test -p 1).
+func NewTestStore() (*SQLStore, error) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		return NewMemoryStore()
+	}
+
+	s, err := NewPostgresStore(dsn)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(`
+		TRUNCATE users, games, game_players, throws, legs, visits, player_ratings,
+			rating_history, achievements, game_player_set_stats, user_stats,
+			accounts, sessions, api_tokens
+		RESTART IDENTITY CASCADE
+	`)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+// Timeline is the complete turn-by-turn course of a game, used to replay it
+type Timeline struct {
+	GameID    int                 `json:"game_id"`
+	Status    models.GameStatus   `json:"status"`
+	Settings  models.GameSettings `json:"settings"`
+	WinnerID  *int                `json:"winner_id,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	Players   []models.GamePlayer `json:"players"`
+	Sets      []TimelineSet       `json:"sets"`
+}
+
+// TimelineSet is one set of a game
+type TimelineSet struct {
+	SetNo    int           `json:"set_no"`
+	WinnerID *int          `json:"winner_id,omitempty"`
+	Legs     []TimelineLeg `json:"legs"`
+}
+
+// TimelineLeg is one leg of a set
+type TimelineLeg struct {
+	LegNo      int             `json:"leg_no"`
+	WinnerID   *int            `json:"winner_id,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Visits     []TimelineVisit `json:"visits"`
+}
+
+// TimelineVisit is one player's visit of up to three darts
+type TimelineVisit struct {
+	VisitNo     int            `json:"visit_no"`
+	UserID      int            `json:"user_id"`
+	ScoreBefore int            `json:"score_before"`
+	ScoreAfter  int            `json:"score_after"`
+	Scored      int            `json:"scored"` // 0 for a bust
+	Bust        bool           `json:"bust"`
+	Checkout    bool           `json:"checkout"`
+	StartedAt   time.Time      `json:"started_at"`
+	Darts       []TimelineDart `json:"darts"`
+}
+
+// TimelineDart is a single dart of a visit
+type TimelineDart struct {
+	ThrowID     int       `json:"throw_id"`
+	DartNo      int       `json:"dart_no"`
+	Points      int       `json:"points"`
+	Multiplier  int       `json:"multiplier"`
+	ScoreBefore int       `json:"score_before"`
+	ScoreAfter  int       `json:"score_after"`
+	Bust        bool      `json:"bust"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GetGameTimeline returns the sets, legs, visits and darts of a game in
+// playing order. It is derived from the throws alone: the engine stores the
+// score after every dart, and a bust dart reports the score the visit
+// started with.
+func (s *SQLStore) GetGameTimeline(gameID int) (*Timeline, error) {
+	g, err := s.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, nil
+	}
+
+	rows, err := s.read.Query(`
+		SELECT id, user_id, points, multiplier, valid, score_after, set_no, leg_no, visit_no, dart_no, created_at
+		FROM throws
+		WHERE game_id = ?
+		ORDER BY id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timeline := &Timeline{
+		GameID:    g.ID,
+		Status:    g.Status,
+		Settings:  g.Settings,
+		WinnerID:  g.WinnerID,
+		CreatedAt: g.CreatedAt,
+		Players:   g.Players,
+		Sets:      []TimelineSet{},
+	}
+
+	var (
+		set   *TimelineSet
+		leg   *TimelineLeg
+		visit *TimelineVisit
+	)
+	for rows.Next() {
+		var t models.Throw
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Points, &t.Multiplier, &t.Valid, &t.ScoreAfter,
+			&t.SetNo, &t.LegNo, &t.VisitNo, &t.DartNo, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if set == nil || set.SetNo != t.SetNo {
+			timeline.Sets = append(timeline.Sets, TimelineSet{SetNo: t.SetNo, Legs: []TimelineLeg{}})
+			set = &timeline.Sets[len(timeline.Sets)-1]
+			leg = nil
+		}
+		if leg == nil || leg.LegNo != t.LegNo {
+			set.Legs = append(set.Legs, TimelineLeg{LegNo: t.LegNo, StartedAt: t.CreatedAt, Visits: []TimelineVisit{}})
+			leg = &set.Legs[len(set.Legs)-1]
+			visit = nil
+		}
+		if visit == nil || visit.VisitNo != t.VisitNo {
+			// The first dart of a visit is thrown at the score the visit starts with
+			scoreBefore := t.ScoreAfter
+			if t.Valid {
+				scoreBefore += t.Points * t.Multiplier
+			}
+			leg.Visits = append(leg.Visits, TimelineVisit{
+				VisitNo:     t.VisitNo,
+				UserID:      t.UserID,
+				ScoreBefore: scoreBefore,
+				StartedAt:   t.CreatedAt,
+				Darts:       []TimelineDart{},
+			})
+			visit = &leg.Visits[len(leg.Visits)-1]
+		}
+
+		dart := TimelineDart{
+			ThrowID:     t.ID,
+			DartNo:      t.DartNo,
+			Points:      t.Points,
+			Multiplier:  t.Multiplier,
+			ScoreBefore: visit.ScoreBefore,
+			ScoreAfter:  t.ScoreAfter,
+			Bust:        !t.Valid,
+			CreatedAt:   t.CreatedAt,
+		}
+		if n := len(visit.Darts); n > 0 {
+			dart.ScoreBefore = visit.Darts[n-1].ScoreAfter
+		}
+		visit.Darts = append(visit.Darts, dart)
+
+		visit.ScoreAfter = t.ScoreAfter
+		visit.Bust = visit.Bust || !t.Valid
+		visit.Scored = visit.ScoreBefore - visit.ScoreAfter
+		if t.Valid && t.ScoreAfter == 0 {
+			visit.Checkout = true
+			winnerID := t.UserID
+			finishedAt := t.CreatedAt
+			leg.WinnerID = &winnerID
+			leg.FinishedAt = &finishedAt
+			// Each set is decided by a single leg
+			set.WinnerID = &winnerID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return timeline, nil
+}
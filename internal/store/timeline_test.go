@@ -0,0 +1,70 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestGetGameTimeline(t *testing.T) {
+	s, g, alice, bob := setupLegsGame(t)
+	defer s.Close()
+
+	playDarts(t, s, g.ID, bestOfThreeDarts(alice, bob))
+
+	timeline, err := s.GetGameTimeline(g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	if len(timeline.Sets) != 2 {
+		t.Fatalf("Expected 2 sets, got %d", len(timeline.Sets))
+	}
+
+	set1 := timeline.Sets[0]
+	if set1.SetNo != 1 || set1.WinnerID == nil || *set1.WinnerID != alice {
+		t.Errorf("Expected Alice to win set 1, got %+v", set1)
+	}
+	if len(set1.Legs) != 1 || len(set1.Legs[0].Visits) != 3 {
+		t.Fatalf("Expected 1 leg with 3 visits in set 1, got %+v", set1.Legs)
+	}
+	leg1 := set1.Legs[0]
+	if leg1.FinishedAt == nil || leg1.WinnerID == nil || *leg1.WinnerID != alice {
+		t.Errorf("Expected leg 1 finished by Alice, got %+v", leg1)
+	}
+	if v := leg1.Visits[0]; v.UserID != alice || v.ScoreBefore != 101 || v.ScoreAfter != 40 || v.Scored != 61 || len(v.Darts) != 3 {
+		t.Errorf("Expected Alice's first visit 101 -> 40 with 3 darts, got %+v", v)
+	}
+	if v := leg1.Visits[2]; !v.Checkout || v.ScoreBefore != 40 || v.ScoreAfter != 0 || len(v.Darts) != 1 {
+		t.Errorf("Expected Alice's checkout from 40, got %+v", v)
+	}
+
+	set2 := timeline.Sets[1]
+	if set2.WinnerID == nil || *set2.WinnerID != bob {
+		t.Errorf("Expected Bob to win set 2, got %+v", set2)
+	}
+	if len(set2.Legs) != 1 || len(set2.Legs[0].Visits) != 3 {
+		t.Fatalf("Expected 1 leg with 3 visits in set 2, got %+v", set2.Legs)
+	}
+
+	// Alice's bust: T20 to 41, then T20 is reverted to the visit's start
+	bust := set2.Legs[0].Visits[1]
+	if bust.UserID != alice || !bust.Bust || bust.ScoreBefore != 101 || bust.ScoreAfter != 101 || bust.Scored != 0 {
+		t.Errorf("Expected Alice's bust visit at 101, got %+v", bust)
+	}
+	if len(bust.Darts) != 2 {
+		t.Fatalf("Expected 2 darts in the bust visit, got %d", len(bust.Darts))
+	}
+	if d := bust.Darts[0]; d.Bust || d.ScoreBefore != 101 || d.ScoreAfter != 41 {
+		t.Errorf("Expected first dart 101 -> 41, got %+v", d)
+	}
+	if d := bust.Darts[1]; !d.Bust || d.DartNo != 2 || d.ScoreBefore != 41 || d.ScoreAfter != 101 {
+		t.Errorf("Expected bust dart 41 -> 101, got %+v", d)
+	}
+
+	// Unknown games have no timeline
+	missing, err := s.GetGameTimeline(g.ID + 1)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	if missing != nil {
+		t.Errorf("Expected no timeline for an unknown game, got %+v", missing)
+	}
+}
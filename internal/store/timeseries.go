@@ -0,0 +1,198 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
+)
+
+var (
+	ErrUnknownMetric = errors.New("unknown metric")
+	ErrUnknownBucket = errors.New("unknown bucket")
+)
+
+// Metric names shared by trend series and leaderboards
+const (
+	MetricAverage3Dart       = "average_3_dart"
+	MetricCheckoutPercentage = "checkout_percentage"
+	MetricGamesPlayed        = "games_played"
+	MetricWinRate            = "win_rate"
+	MetricMaximums180        = "maximums_180"
+	MetricHighestCheckout    = "highest_checkout"
+)
+
+// TimeSeriesPoint is the value of a metric within one bucket. Samples is the
+// number of darts (or games) the value is based on.
+type TimeSeriesPoint struct {
+	Bucket  string  `json:"bucket"` // Start date of the bucket, YYYY-MM-DD
+	Value   float64 `json:"value"`
+	Samples int     `json:"samples"`
+}
+
+// TimeSeries is a bucketed metric series for one player
+type TimeSeries struct {
+	UserID int               `json:"user_id"`
+	Metric string            `json:"metric"`
+	Bucket string            `json:"bucket"`
+	Points []TimeSeriesPoint `json:"points"`
+}
+
+// GetUserTimeSeries returns a metric for a player grouped into day, week or
+// month buckets. Only finished games are included, like GetUserStats.
+// The filter restricts the range or game; its UserID is replaced by userID.
+func (s *SQLStore) GetUserTimeSeries(userID int, metric, bucket string, filter ThrowFilter) (*TimeSeries, error) {
+	filter.UserID = userID
+
+	var (
+		points []TimeSeriesPoint
+		err    error
+	)
+	switch metric {
+	case MetricAverage3Dart:
+		points, err = s.averageSeries(bucket, filter)
+	case MetricCheckoutPercentage:
+		points, err = s.checkoutSeries(bucket, filter)
+	case MetricGamesPlayed, MetricWinRate:
+		points, err = s.gamesSeries(metric, bucket, filter)
+	default:
+		return nil, ErrUnknownMetric
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if points == nil {
+		points = []TimeSeriesPoint{}
+	}
+	return &TimeSeries{UserID: userID, Metric: metric, Bucket: bucket, Points: points}, nil
+}
+
+func (s *SQLStore) averageSeries(bucket string, filter ThrowFilter) ([]TimeSeriesPoint, error) {
+	expr, err := s.db.dialect.bucketExpr(bucket, "t.created_at")
+	if err != nil {
+		return nil, err
+	}
+	where, args := filter.where()
+
+	rows, err := s.read.Query(`
+		SELECT `+expr+` AS bucket,
+			COALESCE(SUM(CASE WHEN t.valid = 1 THEN t.points * t.multiplier ELSE 0 END), 0),
+			COUNT(*)
+		FROM throws t
+		JOIN games g ON t.game_id = g.id
+		WHERE `+where+` AND g.status = ?
+		GROUP BY bucket
+		ORDER BY bucket`, append(args, models.GameStatusFinished)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []TimeSeriesPoint
+	for rows.Next() {
+		var p TimeSeriesPoint
+		var totalPoints int
+		if err := rows.Scan(&p.Bucket, &totalPoints, &p.Samples); err != nil {
+			return nil, err
+		}
+		if p.Samples > 0 {
+			p.Value = (float64(totalPoints) / float64(p.Samples)) * 3
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *SQLStore) checkoutSeries(bucket string, filter ThrowFilter) ([]TimeSeriesPoint, error) {
+	expr, err := s.db.dialect.bucketExpr(bucket, "t.created_at")
+	if err != nil {
+		return nil, err
+	}
+	where, args := filter.where()
+
+	// The window needs the whole leg, so the date range is applied after
+	// computing the score before each dart
+	rows, err := s.read.Query(`
+		SELECT bucket,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN valid = 1 AND score_after = 0 THEN 1 ELSE 0 END), 0)
+		FROM (
+			SELECT `+expr+` AS bucket, t.valid, t.score_after, `+scoreBeforeExpr+` AS score_before,
+				t.user_id, t.game_id, t.created_at
+			FROM throws t
+			JOIN games g ON t.game_id = g.id
+			WHERE t.user_id = ? AND g.status = ?
+		) t
+		WHERE `+where+` AND `+checkoutAttemptCondition+`
+		GROUP BY bucket
+		ORDER BY bucket`, append([]interface{}{filter.UserID, models.GameStatusFinished}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []TimeSeriesPoint
+	for rows.Next() {
+		var p TimeSeriesPoint
+		var hits int
+		if err := rows.Scan(&p.Bucket, &p.Samples, &hits); err != nil {
+			return nil, err
+		}
+		if p.Samples > 0 {
+			p.Value = float64(hits) / float64(p.Samples) * 100
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *SQLStore) gamesSeries(metric, bucket string, filter ThrowFilter) ([]TimeSeriesPoint, error) {
+	expr, err := s.db.dialect.bucketExpr(bucket, "g.created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + expr + ` AS bucket,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN g.winner_id = gp.user_id THEN 1 ELSE 0 END), 0)
+		FROM game_players gp
+		JOIN games g ON gp.game_id = g.id
+		WHERE gp.user_id = ? AND g.status = ?`
+	args := []interface{}{filter.UserID, models.GameStatusFinished}
+	if filter.GameID != 0 {
+		query += ` AND g.id = ?`
+		args = append(args, filter.GameID)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND g.created_at >= ?`
+		args = append(args, filter.From.UTC().Format(sqliteTimeFormat))
+	}
+	if !filter.To.IsZero() {
+		query += ` AND g.created_at < ?`
+		args = append(args, filter.To.UTC().Format(sqliteTimeFormat))
+	}
+	query += ` GROUP BY bucket ORDER BY bucket`
+
+	rows, err := s.read.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []TimeSeriesPoint
+	for rows.Next() {
+		var p TimeSeriesPoint
+		var wins int
+		if err := rows.Scan(&p.Bucket, &p.Samples, &wins); err != nil {
+			return nil, err
+		}
+		if metric == MetricGamesPlayed {
+			p.Value = float64(p.Samples)
+		} else if p.Samples > 0 {
+			p.Value = float64(wins) / float64(p.Samples) * 100
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -3,41 +3,179 @@ package store
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/michaelschlottmann/darts-web/internal/models"
 )
 
-var ErrDuplicateUsername = errors.New("username already exists")
+var (
+	ErrDuplicateUsername = errors.New("username already exists")
+	ErrUserInActiveGame  = errors.New("user is playing an unfinished game")
+	ErrUnknownPlayer     = errors.New("player does not exist or is archived")
+	ErrInvalidPurgeMode  = errors.New("invalid purge mode")
+)
 
-func (s *Store) CreateUser(name string) (*models.User, error) {
-	// First check if user already exists
+// PurgeMode selects what PurgeUser does with a player's history
+type PurgeMode string
+
+const (
+	// PurgeAnonymise keeps the games but replaces the player's name, so the
+	// statistics of their opponents stay unchanged
+	PurgeAnonymise PurgeMode = "anonymise"
+	// PurgeDelete deletes the player and every game they played, including
+	// the throws of their opponents in those games
+	PurgeDelete PurgeMode = "delete"
+)
+
+// UserUpdate holds the profile changes of UpdateUser. Nil fields are left
+// unchanged.
+type UserUpdate struct {
+	Name         *string
+	Nickname     *string
+	WalkOnColour *string
+	Preferences  *models.GamePreferences
+}
+
+// checkNameAvailable returns ErrDuplicateUsername if another player than
+// exceptID has the name, ignoring case
+func checkNameAvailable(q queryer, name string, exceptID int) error {
 	var exists bool
-	checkQuery := `SELECT EXISTS(SELECT 1 FROM users WHERE name = ?)`
-	err := s.db.QueryRow(checkQuery, name).Scan(&exists)
+	err := q.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE name_key = ? AND id <> ?)`, nameKey(name), exceptID).Scan(&exists)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if exists {
-		return nil, ErrDuplicateUsername
+		return ErrDuplicateUsername
 	}
+	return nil
+}
+
+// CreateUser adds a player. The name is normalised and must differ from the
+// other players' names in more than case.
+func (s *SQLStore) CreateUser(name string) (*models.User, error) {
+	name = NormaliseName(name)
 
-	// Insert new user
+	// First check if user already exists
+	if err := checkNameAvailable(s.db, name, 0); err != nil {
+		return nil, err
+	}
+
+	// Insert new user. The unique name_key index catches a player added with
+	// the same name since the check.
 	query := `
-		INSERT INTO users (name)
-		VALUES (?)
-		RETURNING id, name, created_at
+		INSERT INTO users (name, name_key)
+		VALUES (?, ?)
+		RETURNING ` + userColumns + `
 	`
-	var user models.User
-	err = s.db.QueryRow(query, name).Scan(&user.ID, &user.Name, &user.CreatedAt)
+	user, err := scanUser(s.db.QueryRow(query, name, nameKey(name)))
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateUsername
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (s *Store) ListUsers() ([]models.User, error) {
-	query := `SELECT id, name, created_at FROM users ORDER BY name`
-	rows, err := s.db.Query(query)
+// userColumns are the columns scanned by scanUser
+const userColumns = `id, name, created_at, archived_at, nickname, avatar, walk_on_colour,
+	pref_total_points, pref_best_of, pref_double_out`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Name, &u.CreatedAt, &u.ArchivedAt, &u.Nickname, &u.Avatar, &u.WalkOnColour,
+		&u.Preferences.TotalPoints, &u.Preferences.BestOf, &u.Preferences.DoubleOut)
+	return u, err
+}
+
+// UpdateUser changes a player's profile and returns the updated player, or
+// sql.ErrNoRows if there is none. A new name must not be taken by another
+// player.
+func (s *SQLStore) UpdateUser(id int, update UserUpdate) (*models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	u, err := scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		name := NormaliseName(*update.Name)
+		if err := checkNameAvailable(tx, name, id); err != nil {
+			return nil, err
+		}
+		u.Name = name
+	}
+	if update.Nickname != nil {
+		u.Nickname = *update.Nickname
+	}
+	if update.WalkOnColour != nil {
+		u.WalkOnColour = *update.WalkOnColour
+	}
+	if update.Preferences != nil {
+		u.Preferences = *update.Preferences
+	}
+
+	// NULL stands for no double out preference
+	var doubleOut interface{}
+	if u.Preferences.DoubleOut != nil {
+		doubleOut = *u.Preferences.DoubleOut
+	}
+	_, err = tx.Exec(`
+		UPDATE users
+		SET name = ?, name_key = ?, nickname = ?, walk_on_colour = ?, pref_total_points = ?, pref_best_of = ?, pref_double_out = ?
+		WHERE id = ?`,
+		u.Name, nameKey(u.Name), u.Nickname, u.WalkOnColour, u.Preferences.TotalPoints, u.Preferences.BestOf, doubleOut, id)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateUsername
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SetUserAvatar records the file name of a player's profile picture, empty
+// for none, and returns the previous one so its file can be removed
+func (s *SQLStore) SetUserAvatar(id int, avatar string) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	if err := tx.QueryRow(`SELECT avatar FROM users WHERE id = ?`, id).Scan(&previous); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`UPDATE users SET avatar = ? WHERE id = ?`, avatar, id); err != nil {
+		return "", err
+	}
+
+	return previous, tx.Commit()
+}
+
+// ListUsers returns the active players by name
+func (s *SQLStore) ListUsers() ([]models.User, error) {
+	return s.listUsers(`archived_at IS NULL`)
+}
+
+// ListArchivedUsers returns the archived players by name
+func (s *SQLStore) ListArchivedUsers() ([]models.User, error) {
+	return s.listUsers(`archived_at IS NOT NULL`)
+}
+
+func (s *SQLStore) listUsers(where string) ([]models.User, error) {
+	rows, err := s.read.Query(`SELECT ` + userColumns + ` FROM users WHERE ` + where + ` ORDER BY name_key, id`)
 	if err != nil {
 		return nil, err
 	}
@@ -45,19 +183,72 @@ func (s *Store) ListUsers() ([]models.User, error) {
 
 	var users []models.User
 	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.CreatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, u)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
-func (s *Store) GetUser(id int) (*models.User, error) {
-	query := `SELECT id, name, created_at FROM users WHERE id = ?`
-	var u models.User
-	err := s.db.QueryRow(query, id).Scan(&u.ID, &u.Name, &u.CreatedAt)
+// UserFilter selects the players of SearchUsers
+type UserFilter struct {
+	// Query matches the start of the name, ignoring case
+	Query    string
+	Archived bool
+	// Limit is the page size, 0 for all players. Offset skips players and
+	// applies with a limit only.
+	Limit  int
+	Offset int
+}
+
+// SearchUsers returns a page of the players matching the filter by name,
+// and the number of matching players on all pages
+func (s *SQLStore) SearchUsers(f UserFilter) ([]models.User, int, error) {
+	where := `archived_at IS NULL`
+	if f.Archived {
+		where = `archived_at IS NOT NULL`
+	}
+	var args []interface{}
+	if f.Query != "" {
+		// The key is case folded already, so LIKE only needs to match the
+		// prefix. Its wildcards are escaped.
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(nameKey(f.Query))
+		where += ` AND name_key LIKE ? ESCAPE '\'`
+		args = append(args, escaped+"%")
+	}
+
+	var total int
+	if err := s.read.QueryRow(`SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + where + ` ORDER BY name_key, id`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := s.read.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+// GetUser returns a player, including archived ones, or nil if there is none
+func (s *SQLStore) GetUser(id int) (*models.User, error) {
+	u, err := scanUser(s.read.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -67,21 +258,172 @@ func (s *Store) GetUser(id int) (*models.User, error) {
 	return &u, nil
 }
 
-func (s *Store) DeleteUser(id int) error {
-	query := `DELETE FROM users WHERE id = ?`
-	result, err := s.db.Exec(query, id)
+// DeleteUser removes a player. Players with games are archived instead, so
+// their history stays intact. Players in an unfinished game are refused.
+func (s *SQLStore) DeleteUser(id int) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	if err := checkUserDeletable(tx, id); err != nil {
+		return err
+	}
+
+	var played bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM game_players WHERE user_id = ?)`, id).Scan(&played)
+	if err != nil {
+		return err
+	}
+	if played {
+		_, err = tx.Exec(`UPDATE users SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL`, id)
+	} else {
+		_, err = tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
+	return tx.Commit()
+}
+
+// checkUserDeletable returns sql.ErrNoRows for unknown players and
+// ErrUserInActiveGame for players in an unfinished game
+func checkUserDeletable(tx *txn, id int) error {
+	var exists, active bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM users WHERE id = ?),
+			EXISTS(SELECT 1 FROM game_players gp JOIN games g ON g.id = gp.game_id
+				WHERE gp.user_id = ? AND g.status <> ?)`,
+		id, id, models.GameStatusFinished).Scan(&exists, &active)
+	if err != nil {
+		return err
+	}
+	if !exists {
 		return sql.ErrNoRows
 	}
+	if active {
+		return ErrUserInActiveGame
+	}
+	return nil
+}
 
+// UnarchiveUser returns an archived player to the player list
+func (s *SQLStore) UnarchiveUser(id int) error {
+	result, err := s.db.Exec(`UPDATE users SET archived_at = NULL WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
+
+// PurgeUser removes a player's personal data for good and returns the number
+// of games deleted. The caller removes the avatar file. Ratings and statistics
+// are recalculated, as the history of other players may change. Players in an
+// unfinished game are refused.
+func (s *SQLStore) PurgeUser(id int, mode PurgeMode) (int, error) {
+	if mode != PurgeAnonymise && mode != PurgeDelete {
+		return 0, ErrInvalidPurgeMode
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkUserDeletable(tx, id); err != nil {
+		return 0, err
+	}
+
+	if mode == PurgeAnonymise {
+		// A player may already be called "Deleted player 7", so the
+		// placeholder gets a number until it is free
+		name := fmt.Sprintf("Deleted player %d", id)
+		for n := 2; ; n++ {
+			err := checkNameAvailable(tx, name, id)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, ErrDuplicateUsername) {
+				return 0, err
+			}
+			name = fmt.Sprintf("Deleted player %d (%d)", id, n)
+		}
+		_, err := tx.Exec(`
+			UPDATE users
+			SET name = ?, name_key = ?, nickname = '', avatar = '', walk_on_colour = '',
+				pref_total_points = 0, pref_best_of = 0, pref_double_out = NULL,
+				archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP)
+			WHERE id = ?`,
+			name, nameKey(name), id)
+		if err != nil {
+			return 0, err
+		}
+		return 0, tx.Commit()
+	}
+
+	gameIDs, err := queryUserGameIDs(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	for _, gameID := range gameIDs {
+		if err := deleteGame(tx, gameID); err != nil {
+			return 0, err
+		}
+	}
+	for _, table := range []string{"achievements", "rating_history", "player_ratings", "user_stats"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE user_id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+
+	if _, err := recomputeRatings(tx); err != nil {
+		return 0, err
+	}
+	if _, err := rebuildStatsAggregates(tx); err != nil {
+		return 0, err
+	}
+
+	return len(gameIDs), tx.Commit()
+}
+
+func queryUserGameIDs(tx *txn, userID int) ([]int, error) {
+	rows, err := tx.Query(`SELECT game_id FROM game_players WHERE user_id = ? ORDER BY game_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteGame deletes a game with everything recorded for it, children first
+func deleteGame(tx *txn, gameID int) error {
+	for _, table := range []string{"achievements", "rating_history", "game_player_set_stats", "visits", "legs", "throws", "game_players"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE game_id = ?`, gameID); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`DELETE FROM games WHERE id = ?`, gameID)
+	return err
+}
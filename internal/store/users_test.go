@@ -1,16 +1,17 @@
 package store
 
 import (
-	"os"
+	"database/sql"
+	"fmt"
 	"testing"
+	"time"
+
+	"github.com/michaelschlottmann/darts-web/internal/models"
 )
 
 func TestCreateUser_DuplicateRejection(t *testing.T) {
 	// Create temporary database
-	dbPath := "./test_users.db"
-	defer os.Remove(dbPath)
-
-	store, err := NewStore(dbPath)
+	store, err := openTestStore(t)
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
@@ -37,11 +38,66 @@ func TestCreateUser_DuplicateRejection(t *testing.T) {
 	}
 }
 
-func TestListUsers(t *testing.T) {
-	dbPath := "./test_list.db"
-	defer os.Remove(dbPath)
+func TestIsUniqueViolation(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.CreateUser("TestPlayer"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// What CreateUser runs into when another request adds the name after
+	// its check
+	_, err = s.db.Exec("INSERT INTO users (name, name_key) VALUES (?, ?)", "testplayer", nameKey("testplayer"))
+	if !isUniqueViolation(err) {
+		t.Errorf("Expected a unique violation, got %v", err)
+	}
+	_, err = s.db.Exec("INSERT INTO users (name) VALUES (NULL)")
+	if err == nil || isUniqueViolation(err) {
+		t.Errorf("Expected a different constraint error, got %v", err)
+	}
+}
 
-	store, err := NewStore(dbPath)
+func TestCreateUser_ReturnsStoredRow(t *testing.T) {
+	store, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	before := time.Now().UTC().Add(-time.Minute)
+	user, err := store.CreateUser("Alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if user.ID == 0 || user.Name != "Alice" {
+		t.Errorf("Expected Alice with an ID, got %+v", user)
+	}
+	if user.CreatedAt.Before(before) || user.CreatedAt.After(before.Add(2*time.Minute)) {
+		t.Errorf("Expected created_at close to now, got %v", user.CreatedAt)
+	}
+
+	stored, err := store.GetUser(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if stored == nil || !stored.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("Expected stored user to match %+v, got %+v", user, stored)
+	}
+
+	var version int
+	if err := store.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if latest := latestVersion(); version != latest {
+		t.Errorf("Expected schema version %d, got %d", latest, version)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	store, err := openTestStore(t)
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
@@ -76,10 +132,7 @@ func TestListUsers(t *testing.T) {
 }
 
 func TestDeleteUser(t *testing.T) {
-	dbPath := "./test_delete.db"
-	defer os.Remove(dbPath)
-
-	store, err := NewStore(dbPath)
+	store, err := openTestStore(t)
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
@@ -112,3 +165,252 @@ func TestDeleteUser(t *testing.T) {
 		t.Errorf("Expected error when deleting non-existent user, got nil")
 	}
 }
+
+// setupFinishedGame creates Alice and Bob and a 101 best-of-1 won by Alice
+func setupFinishedGame(t *testing.T) (*SQLStore, *models.Game, int, int) {
+	t.Helper()
+	s, g, alice, bob := setupLegsGame(t)
+
+	finished, err := s.CreateGame(101, 1, true, []int{alice, bob})
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	// The best-of-3 from setupLegsGame would keep both players busy
+	if err := deleteTestGame(s, g.ID); err != nil {
+		t.Fatalf("Failed to delete game: %v", err)
+	}
+	playDarts(t, s, finished.ID, bestOfThreeDarts(alice, bob)[:7])
+	return s, finished, alice, bob
+}
+
+func deleteTestGame(s *SQLStore, gameID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := deleteGame(tx, gameID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func TestDeleteUser_InActiveGame(t *testing.T) {
+	s, _, alice, _ := setupLegsGame(t)
+	defer s.Close()
+
+	if err := s.DeleteUser(alice); err != ErrUserInActiveGame {
+		t.Fatalf("Expected ErrUserInActiveGame, got %v", err)
+	}
+	if _, err := s.PurgeUser(alice, PurgeDelete); err != ErrUserInActiveGame {
+		t.Fatalf("Expected ErrUserInActiveGame purging, got %v", err)
+	}
+}
+
+func TestDeleteUser_ArchivesPlayers(t *testing.T) {
+	s, g, alice, bob := setupFinishedGame(t)
+	defer s.Close()
+
+	if err := s.DeleteUser(alice); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	u, err := s.GetUser(alice)
+	if err != nil || u == nil || u.ArchivedAt == nil {
+		t.Fatalf("Expected Alice to be archived, got %+v, %v", u, err)
+	}
+	users, err := s.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != bob {
+		t.Errorf("Expected only Bob in the player list, got %+v", users)
+	}
+	archived, err := s.ListArchivedUsers()
+	if err != nil {
+		t.Fatalf("Failed to list archived users: %v", err)
+	}
+	if len(archived) != 1 || archived[0].ID != alice {
+		t.Errorf("Expected Alice in the archive, got %+v", archived)
+	}
+
+	stats, err := gameStatistics(t, s, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get statistics: %v", err)
+	}
+	if stats.Players[0].UserName != "Alice" {
+		t.Errorf("Expected the game to keep Alice, got %q", stats.Players[0].UserName)
+	}
+	if _, err := s.CreateGame(301, 1, true, []int{alice, bob}); err != ErrUnknownPlayer {
+		t.Errorf("Expected ErrUnknownPlayer for an archived player, got %v", err)
+	}
+
+	if err := s.UnarchiveUser(alice); err != nil {
+		t.Fatalf("Failed to unarchive user: %v", err)
+	}
+	if users, _ := s.ListUsers(); len(users) != 2 {
+		t.Errorf("Expected both players after unarchiving, got %+v", users)
+	}
+}
+
+func TestPurgeUser_Anonymise(t *testing.T) {
+	s, g, alice, _ := setupFinishedGame(t)
+	defer s.Close()
+	nickname := "Ali"
+	if _, err := s.UpdateUser(alice, UserUpdate{Nickname: &nickname}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	games, err := s.PurgeUser(alice, PurgeAnonymise)
+	if err != nil {
+		t.Fatalf("Failed to purge user: %v", err)
+	}
+	if games != 0 {
+		t.Errorf("Expected no games deleted, got %d", games)
+	}
+
+	u, err := s.GetUser(alice)
+	if err != nil || u == nil {
+		t.Fatalf("Failed to get user: %+v, %v", u, err)
+	}
+	if u.Name == "Alice" || u.Nickname != "" || u.ArchivedAt == nil {
+		t.Errorf("Expected an archived player without name, got %+v", u)
+	}
+	stats, err := gameStatistics(t, s, g.ID)
+	if err != nil {
+		t.Fatalf("Failed to get statistics: %v", err)
+	}
+	if stats.Players[0].UserName != u.Name || stats.Players[0].OverallStats.TotalThrows != 4 {
+		t.Errorf("Expected the anonymised player's throws to stay, got %+v", stats.Players[0])
+	}
+}
+
+func TestPurgeUser_AnonymiseNameTaken(t *testing.T) {
+	s, _, alice, bob := setupFinishedGame(t)
+	defer s.Close()
+	taken := fmt.Sprintf("Deleted player %d", alice)
+	if _, err := s.UpdateUser(bob, UserUpdate{Name: &taken}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	if _, err := s.PurgeUser(alice, PurgeAnonymise); err != nil {
+		t.Fatalf("Failed to purge user: %v", err)
+	}
+	u, err := s.GetUser(alice)
+	if err != nil || u == nil {
+		t.Fatalf("Failed to get user: %+v, %v", u, err)
+	}
+	if u.Name == "Alice" || u.Name == taken {
+		t.Errorf("Expected a free placeholder name, got %q", u.Name)
+	}
+}
+
+func TestPurgeUser_Delete(t *testing.T) {
+	s, g, alice, bob := setupFinishedGame(t)
+	defer s.Close()
+	if _, err := s.RecomputeRatings(); err != nil {
+		t.Fatalf("Failed to compute ratings: %v", err)
+	}
+
+	if _, err := s.PurgeUser(alice, "everything"); err != ErrInvalidPurgeMode {
+		t.Errorf("Expected ErrInvalidPurgeMode, got %v", err)
+	}
+	games, err := s.PurgeUser(alice, PurgeDelete)
+	if err != nil {
+		t.Fatalf("Failed to purge user: %v", err)
+	}
+	if games != 1 {
+		t.Errorf("Expected 1 game deleted, got %d", games)
+	}
+
+	if u, err := s.GetUser(alice); err != nil || u != nil {
+		t.Errorf("Expected Alice to be gone, got %+v, %v", u, err)
+	}
+	if game, err := s.GetGame(g.ID); err != nil || game != nil {
+		t.Errorf("Expected the game to be gone, got %+v, %v", game, err)
+	}
+	var throws int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM throws`).Scan(&throws); err != nil {
+		t.Fatalf("Failed to count throws: %v", err)
+	}
+	if throws != 0 {
+		t.Errorf("Expected no throws left, got %d", throws)
+	}
+
+	stats, err := s.GetUserStats(bob)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalGames != 0 {
+		t.Errorf("Expected Bob's stats without the deleted game, got %d games", stats.TotalGames)
+	}
+	ratings, err := s.GetRatingLeaderboard()
+	if err != nil {
+		t.Fatalf("Failed to get ratings: %v", err)
+	}
+	if len(ratings) != 0 {
+		t.Errorf("Expected no ratings left, got %+v", ratings)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	s, err := openTestStore(t)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	alice, err := s.CreateUser("Alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := s.CreateUser("Bob"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	bob := "Bob"
+	if _, err := s.UpdateUser(alice.ID, UserUpdate{Name: &bob}); err != ErrDuplicateUsername {
+		t.Errorf("Expected ErrDuplicateUsername, got %v", err)
+	}
+	if _, err := s.UpdateUser(42, UserUpdate{}); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for a missing user, got %v", err)
+	}
+
+	name, nickname, colour := "Alicia", "The Arrow", "#ff8800"
+	doubleOut := true
+	_, err = s.UpdateUser(alice.ID, UserUpdate{
+		Name:         &name,
+		Nickname:     &nickname,
+		WalkOnColour: &colour,
+		Preferences:  &models.GamePreferences{TotalPoints: 501, BestOf: 3, DoubleOut: &doubleOut},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	// Fields left out stay as they are
+	if _, err := s.UpdateUser(alice.ID, UserUpdate{Name: &name}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	u, err := s.GetUser(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	p := u.Preferences
+	if u.Name != name || u.Nickname != nickname || u.WalkOnColour != colour ||
+		p.TotalPoints != 501 || p.BestOf != 3 || p.DoubleOut == nil || !*p.DoubleOut {
+		t.Errorf("Expected the updated profile, got %+v", u)
+	}
+
+	previous, err := s.SetUserAvatar(alice.ID, "1-1.png")
+	if err != nil || previous != "" {
+		t.Fatalf("Expected no previous avatar, got %q, %v", previous, err)
+	}
+	previous, err = s.SetUserAvatar(alice.ID, "1-2.png")
+	if err != nil || previous != "1-1.png" {
+		t.Errorf("Expected the first avatar as previous, got %q, %v", previous, err)
+	}
+	if _, err := s.SetUserAvatar(42, "42-1.png"); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for a missing user, got %v", err)
+	}
+}